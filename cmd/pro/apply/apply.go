@@ -7,18 +7,20 @@ import (
 	"os"
 
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 	"gopkg.in/yaml.v3"
 
 	"github.com/nsxbet/proliferate/pkg/core"
+	"github.com/nsxbet/proliferate/pkg/mygit"
 	"github.com/nsxbet/proliferate/pkg/pullrequest"
 )
 
 type applyCommand struct {
-	valuesFile string
-	prFile     string
-	dryRun     bool
-	core       core.Core
+	valuesFile  string
+	prFile      string
+	planFile    string
+	dryRun      bool
+	configRepos string
+	core        core.Core
 }
 
 func NewCommand(c core.Core) *cobra.Command {
@@ -31,8 +33,9 @@ func NewCommand(c core.Core) *cobra.Command {
 
 	cmd.Flags().StringVarP(&ac.valuesFile, "values", "f", "", "Path to values YAML file")
 	cmd.Flags().StringVarP(&ac.prFile, "pr", "p", "", "Path to pull request YAML file")
+	cmd.Flags().StringVar(&ac.planFile, "plan", "", "Path to a plan file from pro pr plan to apply instead of a template")
 	cmd.Flags().BoolVar(&ac.dryRun, "dry-run", false, "Print the parsed pull requests without applying")
-	cmd.MarkFlagRequired("pr")
+	cmd.Flags().StringVar(&ac.configRepos, "config-repos", "", "How to use the repo list from the config file: override or intersect (repo-less templates are always fanned out)")
 
 	return cmd
 }
@@ -41,12 +44,48 @@ func (ac *applyCommand) run(cmd *cobra.Command, args []string) error {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	token := viper.GetString("github-token")
-	if token == "" {
-		return fmt.Errorf("no GitHub token found in environment or config file")
+	var prSet *pullrequest.PullRequestSet
+	switch {
+	case ac.planFile != "" && (ac.prFile != "" || ac.valuesFile != "" || ac.configRepos != ""):
+		return fmt.Errorf("--plan already holds the rendered pull requests, it cannot be combined with --pr, --values or --config-repos")
+	case ac.planFile != "":
+		plan, err := pullrequest.LoadPlan(ac.planFile)
+		if err != nil {
+			return err
+		}
+		prSet = pullrequest.NewPlannedSet(plan, ac.core.Git, ac.core.Printer)
+	case ac.prFile != "":
+		var err error
+		if prSet, err = loadPullRequestSet(ac.core, ac.valuesFile, ac.prFile, ac.configRepos); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("either --pr or --plan is required")
 	}
 
-	valuesData, err := os.ReadFile(ac.valuesFile)
+	prs := prSet.GetPRs()
+	errors := forEachPR(len(prs), func(i int) error {
+		err := prSet.ProcessPR(ctx, i, prs[i], ac.dryRun)
+		if err != nil {
+			ac.core.Printer.PrintError("Failed to process PR %d: %v\n", i+1, err)
+		}
+		return err
+	})
+
+	pruneCache(ac.core)
+
+	// Return combined errors if any occurred
+	if len(errors) > 0 {
+		return fmt.Errorf("failed to process %d pull requests: %v", len(errors), errors)
+	}
+
+	return nil
+}
+
+// loadPullRequestSet renders the PR template with its values and reconciles
+// it with the repos from the config
+func loadPullRequestSet(c core.Core, valuesFile, prFile, configRepos string) (*pullrequest.PullRequestSet, error) {
+	valuesData, err := os.ReadFile(valuesFile)
 	if err != nil {
 		fmt.Printf("failed to read values file: %v", err)
 	}
@@ -56,67 +95,79 @@ func (ac *applyCommand) run(cmd *cobra.Command, args []string) error {
 		decoder := yaml.NewDecoder(bytes.NewBuffer(valuesData))
 		decoder.KnownFields(false)
 		if err := decoder.Decode(&values); err != nil {
-			return fmt.Errorf("failed to parse values file: %v", err)
+			return nil, fmt.Errorf("failed to parse values file: %v", err)
 		}
 	}
 
-	prTemplate, err := os.ReadFile(ac.prFile)
+	prTemplate, err := os.ReadFile(prFile)
 	if err != nil {
-		return fmt.Errorf("failed to read PR template: %v", err)
+		return nil, fmt.Errorf("failed to read PR template: %v", err)
 	}
 
 	templateString, err := renderTemplate("pr", string(prTemplate), values)
 	if err != nil {
-		return fmt.Errorf("failed to render template: %v", err)
+		return nil, fmt.Errorf("failed to render template: %v", err)
 	}
 
-	prSet, err := pullrequest.NewPullRequestSet(templateString, ac.core.Git, ac.core.Printer)
+	prSet, err := pullrequest.NewPullRequestSet(templateString, c.Git, c.Printer)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	prs := prSet.GetPRs()
+	if err := prSet.ApplyConfigRepos(c.Config.GetRepos(), configRepos); err != nil {
+		return nil, err
+	}
+	return prSet, nil
+}
 
+// forEachPR runs fn for each of n pull requests on a worker pool and returns
+// the errors
+func forEachPR(n int, fn func(i int) error) []error {
 	// Create a worker pool
 	workers := 60
-	if workers > len(prs) {
-		workers = len(prs)
+	if workers > n {
+		workers = n
 	}
 
-	jobs := make(chan int, len(prs))
-	results := make(chan error, len(prs))
+	jobs := make(chan int, n)
+	results := make(chan error, n)
 
 	// Start workers
 	for w := 0; w < workers; w++ {
 		go func() {
 			for i := range jobs {
-				err := prSet.ProcessPR(ctx, i, prs[i], ac.dryRun)
-				if err != nil {
-					ac.core.Printer.PrintError("Failed to process PR %d: %v\n", i+1, err)
-				}
-				results <- err
+				results <- fn(i)
 			}
 		}()
 	}
 
 	// Send jobs
-	for i := range prs {
+	for i := 0; i < n; i++ {
 		jobs <- i
 	}
 	close(jobs)
 
 	// Collect results
 	var errors []error
-	for i := 0; i < len(prs); i++ {
+	for i := 0; i < n; i++ {
 		if err := <-results; err != nil {
 			errors = append(errors, err)
 		}
 	}
+	return errors
+}
 
-	// Return combined errors if any occurred
-	if len(errors) > 0 {
-		return fmt.Errorf("failed to process %d pull requests: %v", len(errors), errors)
+// pruneCache keeps the clone cache within its configured size
+func pruneCache(c core.Core) {
+	cfg := c.Config.GetCache()
+	if cfg == nil || cfg.MaxSizeMB <= 0 {
+		return
 	}
 
-	return nil
+	pruned, err := c.Git.PruneCache(mygit.PruneOptions{MaxSize: cfg.MaxSizeMB * 1024 * 1024})
+	if err != nil {
+		c.Printer.PrintError("Failed to prune the clone cache: %v\n", err)
+	} else if len(pruned) > 0 {
+		c.Printer.PrintInfo("Pruned %d mirrors from the clone cache", len(pruned))
+	}
 }
@@ -0,0 +1,70 @@
+package apply
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nsxbet/proliferate/pkg/core"
+	"github.com/nsxbet/proliferate/pkg/pullrequest"
+	"github.com/nsxbet/proliferate/pkg/types"
+)
+
+type planCommand struct {
+	valuesFile  string
+	prFile      string
+	outFile     string
+	configRepos string
+	core        core.Core
+}
+
+func NewPlanCommand(c core.Core) *cobra.Command {
+	pc := &planCommand{core: c}
+	cmd := &cobra.Command{
+		Use:   "plan",
+		Short: "Preview what applying a pull request definition would do, and save it for apply --plan",
+		RunE:  pc.run,
+	}
+
+	cmd.Flags().StringVarP(&pc.valuesFile, "values", "f", "", "Path to values YAML file")
+	cmd.Flags().StringVarP(&pc.prFile, "pr", "p", "", "Path to pull request YAML file")
+	cmd.Flags().StringVarP(&pc.outFile, "out", "o", ".proliferate/plan.yaml", "Path the plan file is written to")
+	cmd.Flags().StringVar(&pc.configRepos, "config-repos", "", "How to use the repo list from the config file: override or intersect (repo-less templates are always fanned out)")
+	cmd.MarkFlagRequired("pr")
+
+	return cmd
+}
+
+func (pc *planCommand) run(cmd *cobra.Command, args []string) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	prSet, err := loadPullRequestSet(pc.core, pc.valuesFile, pc.prFile, pc.configRepos)
+	if err != nil {
+		return err
+	}
+
+	prs := prSet.GetPRs()
+	plan := types.Plan{CreatedAt: time.Now(), Entries: make([]types.PlanEntry, len(prs))}
+	forEachPR(len(prs), func(i int) error {
+		plan.Entries[i] = prSet.PlanPR(ctx, prs[i])
+		return nil
+	})
+
+	pruneCache(pc.core)
+
+	pc.core.Printer.PrintPlan(plan.Entries)
+	if err := pullrequest.SavePlan(pc.outFile, plan); err != nil {
+		return err
+	}
+	pc.core.Printer.PrintInfo("Plan saved to %s, run pro pr apply --plan %s to apply it", pc.outFile, pc.outFile)
+
+	for _, entry := range plan.Entries {
+		if entry.Action == types.PlanActionFail {
+			return fmt.Errorf("some pull requests would fail")
+		}
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package apply
 import (
 	"bytes"
 	"fmt"
+	"regexp"
 	"strings"
 	"text/template"
 )
@@ -13,8 +14,14 @@ var templateFuncs = template.FuncMap{
 		return parts[len(parts)-1]
 	},
 	"lower": strings.ToLower,
+	// slug turns a team name like "Team 1" into the "team-1" slug GitHub expects
+	"slug": func(s string) string {
+		return strings.Trim(slugSeparators.ReplaceAllString(strings.ToLower(s), "-"), "-")
+	},
 }
 
+var slugSeparators = regexp.MustCompile(`[^a-z0-9_.]+`)
+
 func renderTemplate(name string, tmpl string, values interface{}) (string, error) {
 	t, err := template.New(name).Funcs(templateFuncs).Parse(tmpl)
 	if err != nil {
@@ -0,0 +1,76 @@
+package cache
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nsxbet/proliferate/pkg/core"
+	"github.com/nsxbet/proliferate/pkg/mygit"
+)
+
+type pruneCommand struct {
+	maxSizeMB int64
+	olderThan time.Duration
+	all       bool
+	dryRun    bool
+	core      core.Core
+}
+
+// NewCommand groups the commands managing the local clone cache
+func NewCommand(c core.Core) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Clone cache operations",
+	}
+	cmd.AddCommand(newPruneCommand(c))
+	return cmd
+}
+
+func newPruneCommand(c core.Core) *cobra.Command {
+	pc := &pruneCommand{core: c}
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Remove the least recently used repository mirrors from the clone cache",
+		Args:  cobra.NoArgs,
+		RunE:  pc.run,
+	}
+
+	var maxSizeMB int64
+	if cfg := c.Config.GetCache(); cfg != nil {
+		maxSizeMB = cfg.MaxSizeMB
+	}
+	cmd.Flags().Int64Var(&pc.maxSizeMB, "max-size-mb", maxSizeMB, "Shrink the cache to this size, 0 means no limit (defaults to cache.max-size-mb)")
+	cmd.Flags().DurationVar(&pc.olderThan, "older-than", 0, "Remove mirrors not used for this long, e.g. 720h")
+	cmd.Flags().BoolVar(&pc.all, "all", false, "Remove every mirror")
+	cmd.Flags().BoolVar(&pc.dryRun, "dry-run", false, "Print the mirrors that would be removed")
+
+	return cmd
+}
+
+func (pc *pruneCommand) run(cmd *cobra.Command, args []string) error {
+	if pc.maxSizeMB == 0 && pc.olderThan == 0 && !pc.all {
+		return fmt.Errorf("nothing to prune, set --max-size-mb, --older-than or --all")
+	}
+
+	pruned, err := pc.core.Git.PruneCache(mygit.PruneOptions{
+		MaxSize:   pc.maxSizeMB * 1024 * 1024,
+		OlderThan: pc.olderThan,
+		All:       pc.all,
+		DryRun:    pc.dryRun,
+	})
+
+	prefix := ""
+	if pc.dryRun {
+		prefix = "[dry-run] "
+	}
+	var freed int64
+	for _, entry := range pruned {
+		pc.core.Printer.PrintInfo("%sRemoved %s (%d MB, last used %s)", prefix, entry.Repo,
+			entry.Size/1024/1024, entry.LastUsed.Format(time.RFC3339))
+		freed += entry.Size
+	}
+	pc.core.Printer.PrintInfo("%sPruned %d mirrors, %d MB freed", prefix, len(pruned), freed/1024/1024)
+	return err
+}
@@ -0,0 +1,39 @@
+package diff
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/nsxbet/proliferate/pkg/core"
+	"github.com/nsxbet/proliferate/pkg/pullrequest"
+)
+
+type diffCommand struct {
+	stat bool
+	core core.Core
+}
+
+func NewCommand(c core.Core) *cobra.Command {
+	dc := &diffCommand{core: c}
+	cmd := &cobra.Command{
+		Use:   "diff <namespace> [name]",
+		Short: "Show the changes last pushed for the pull requests of a namespace",
+		Args:  cobra.RangeArgs(1, 2),
+		RunE:  dc.run,
+	}
+
+	cmd.Flags().BoolVar(&dc.stat, "stat", false, "Only show the changed files and line counts")
+
+	return cmd
+}
+
+func (dc *diffCommand) run(cmd *cobra.Command, args []string) error {
+	statusMgr := pullrequest.NewPRStatusManager(".proliferate", dc.core.Printer)
+
+	namespace := args[0]
+	prs, err := statusMgr.Select(namespace, args[1:])
+	if err != nil {
+		return err
+	}
+
+	return statusMgr.ShowDiffs(namespace, prs, dc.stat)
+}
@@ -0,0 +1,69 @@
+package merge
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nsxbet/proliferate/cmd/pro/prompt"
+	"github.com/nsxbet/proliferate/pkg/core"
+	"github.com/nsxbet/proliferate/pkg/mygit"
+	"github.com/nsxbet/proliferate/pkg/pullrequest"
+)
+
+type mergeCommand struct {
+	method        string
+	minApprovals  int
+	allowNoChecks bool
+	dryRun        bool
+	yes           bool
+	core          core.Core
+}
+
+func NewCommand(c core.Core) *cobra.Command {
+	mc := &mergeCommand{core: c}
+	cmd := &cobra.Command{
+		Use:   "merge <namespace> [name...]",
+		Short: "Merge the approved pull requests of a namespace whose checks passed",
+		Args:  cobra.MinimumNArgs(1),
+		RunE:  mc.run,
+	}
+
+	cmd.Flags().StringVar(&mc.method, "method", mygit.MergeMethodMerge, "Merge method: merge, squash or rebase")
+	cmd.Flags().IntVar(&mc.minApprovals, "min-approvals", 1, "Approvals a pull request needs before it is merged")
+	cmd.Flags().BoolVar(&mc.allowNoChecks, "allow-no-checks", false, "Merge pull requests that have no CI checks at all")
+	cmd.Flags().BoolVar(&mc.dryRun, "dry-run", false, "Print what would be merged without merging")
+	cmd.Flags().BoolVarP(&mc.yes, "yes", "y", false, "Do not ask for confirmation")
+
+	return cmd
+}
+
+func (mc *mergeCommand) run(cmd *cobra.Command, args []string) error {
+	if err := mygit.ValidateMergeMethod(mc.method); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	statusMgr := pullrequest.NewPRStatusManager(".proliferate", mc.core.Printer)
+
+	namespace := args[0]
+	prs, err := statusMgr.Select(namespace, args[1:])
+	if err != nil {
+		return err
+	}
+
+	if !mc.dryRun && !mc.yes {
+		question := fmt.Sprintf("Merge up to %d pull requests in namespace %s with method %s?", len(prs), namespace, mc.method)
+		if !prompt.Confirm(question) {
+			return fmt.Errorf("aborted")
+		}
+	}
+
+	return statusMgr.Merge(ctx, mc.core.Git, namespace, prs, pullrequest.MergeOptions{
+		Method:        mc.method,
+		MinApprovals:  mc.minApprovals,
+		AllowNoChecks: mc.allowNoChecks,
+		DryRun:        mc.dryRun,
+	})
+}
@@ -0,0 +1,42 @@
+package ready
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nsxbet/proliferate/pkg/core"
+	"github.com/nsxbet/proliferate/pkg/pullrequest"
+)
+
+type readyCommand struct {
+	dryRun bool
+	core   core.Core
+}
+
+func NewCommand(c core.Core) *cobra.Command {
+	rc := &readyCommand{core: c}
+	cmd := &cobra.Command{
+		Use:   "ready <namespace> [name...]",
+		Short: "Mark the draft pull requests of a namespace ready for review",
+		Args:  cobra.MinimumNArgs(1),
+		RunE:  rc.run,
+	}
+
+	cmd.Flags().BoolVar(&rc.dryRun, "dry-run", false, "Print the drafts that would be marked ready")
+
+	return cmd
+}
+
+func (rc *readyCommand) run(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+	statusMgr := pullrequest.NewPRStatusManager(".proliferate", rc.core.Printer)
+
+	namespace := args[0]
+	prs, err := statusMgr.Select(namespace, args[1:])
+	if err != nil {
+		return err
+	}
+
+	return statusMgr.MarkReady(ctx, rc.core.Git, namespace, prs, rc.dryRun)
+}
@@ -0,0 +1,83 @@
+package retract
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nsxbet/proliferate/cmd/pro/prompt"
+	"github.com/nsxbet/proliferate/pkg/core"
+	"github.com/nsxbet/proliferate/pkg/pullrequest"
+)
+
+type retractCommand struct {
+	comment string
+	dryRun  bool
+	yes     bool
+	destroy bool
+	force   bool
+	core    core.Core
+}
+
+// NewCloseCommand closes the pull requests of a namespace on their hosts
+func NewCloseCommand(c core.Core) *cobra.Command {
+	rc := &retractCommand{core: c}
+	cmd := &cobra.Command{
+		Use:   "close <namespace> [name...]",
+		Short: "Close the pull requests of a namespace",
+		Args:  cobra.MinimumNArgs(1),
+		RunE:  rc.run,
+	}
+	rc.addFlags(cmd)
+	return cmd
+}
+
+// NewDestroyCommand closes the pull requests of a namespace, deletes their
+// branches and removes them from the status file
+func NewDestroyCommand(c core.Core) *cobra.Command {
+	rc := &retractCommand{core: c, destroy: true}
+	cmd := &cobra.Command{
+		Use:   "destroy <namespace> [name...]",
+		Short: "Close the pull requests of a namespace and delete their branches and status",
+		Args:  cobra.MinimumNArgs(1),
+		RunE:  rc.run,
+	}
+	rc.addFlags(cmd)
+	cmd.Flags().BoolVar(&rc.force, "force", false, "Also delete branches holding commits not made by proliferate")
+	return cmd
+}
+
+func (rc *retractCommand) addFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&rc.comment, "comment", "m", "", "Comment to leave on each pull request before closing it")
+	cmd.Flags().BoolVar(&rc.dryRun, "dry-run", false, "Print what would be done without changing anything")
+	cmd.Flags().BoolVarP(&rc.yes, "yes", "y", false, "Do not ask for confirmation")
+}
+
+func (rc *retractCommand) run(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+	statusMgr := pullrequest.NewPRStatusManager(".proliferate", rc.core.Printer)
+
+	namespace := args[0]
+	prs, err := statusMgr.Select(namespace, args[1:])
+	if err != nil {
+		return err
+	}
+
+	if !rc.dryRun && !rc.yes {
+		action := "Close"
+		if rc.destroy {
+			action = "Close and delete the branches of"
+		}
+		if !prompt.Confirm(fmt.Sprintf("%s %d pull requests in namespace %s?", action, len(prs), namespace)) {
+			return fmt.Errorf("aborted")
+		}
+	}
+
+	return statusMgr.Retract(ctx, rc.core.Git, namespace, prs, pullrequest.RetractOptions{
+		Comment: rc.comment,
+		Destroy: rc.destroy,
+		Force:   rc.force,
+		DryRun:  rc.dryRun,
+	})
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 
 	"github.com/nsxbet/proliferate/pkg/core"
-	"github.com/nsxbet/proliferate/pkg/mygit"
 	"github.com/nsxbet/proliferate/pkg/pullrequest"
 	"github.com/spf13/cobra"
 )
@@ -21,7 +20,6 @@ func NewCommand(c core.Core) *cobra.Command {
 }
 
 func runStatus(c core.Core, args []string) error {
-	git := mygit.NewGit(c.Config)
 	ctx := context.Background()
 	statusMgr := pullrequest.NewPRStatusManager(".proliferate", c.Printer)
 
@@ -30,5 +28,5 @@ func runStatus(c core.Core, args []string) error {
 	}
 
 	namespace := args[0]
-	return statusMgr.DisplayNamespaceDetails(ctx, namespace, git)
+	return statusMgr.DisplayNamespaceDetails(ctx, namespace, c.Git)
 }
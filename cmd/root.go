@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/charmbracelet/log"
 	"github.com/spf13/cobra"
@@ -11,16 +12,28 @@ import (
 	"go.uber.org/fx/fxevent"
 
 	"github.com/nsxbet/proliferate/cmd/pro/apply"
+	"github.com/nsxbet/proliferate/cmd/pro/cache"
+	"github.com/nsxbet/proliferate/cmd/pro/diff"
+	"github.com/nsxbet/proliferate/cmd/pro/merge"
+	"github.com/nsxbet/proliferate/cmd/pro/ready"
+	"github.com/nsxbet/proliferate/cmd/pro/retract"
 	"github.com/nsxbet/proliferate/cmd/pro/status"
 	"github.com/nsxbet/proliferate/pkg/core"
+	"github.com/nsxbet/proliferate/pkg/redact"
 	"github.com/nsxbet/proliferate/pkg/types"
 )
 
 // Config holds the application configuration
 type Config struct {
-	GithubToken string `yaml:"github-token"`
-	AuthorEmail string `yaml:"author-email"`
-	AuthorName  string `yaml:"author-name"`
+	GithubToken string                 `yaml:"github-token"`
+	AuthorEmail string                 `yaml:"author-email"`
+	AuthorName  string                 `yaml:"author-name"`
+	Repos       []string               `yaml:"repo"`
+	Providers   []types.ProviderConfig `yaml:"providers"`
+	GithubApp   *types.GitHubAppConfig `yaml:"github-app"`
+	Signing     *types.SigningConfig   `yaml:"signing"`
+	Cache       *types.CacheConfig     `yaml:"cache"`
+	GitBackend  string                 `yaml:"git-backend"`
 }
 
 func (c Config) GetAuthorEmail() string {
@@ -35,6 +48,30 @@ func (c Config) GetGithubToken() string {
 	return c.GithubToken
 }
 
+func (c Config) GetRepos() []string {
+	return c.Repos
+}
+
+func (c Config) GetProviders() []types.ProviderConfig {
+	return c.Providers
+}
+
+func (c Config) GetGithubApp() *types.GitHubAppConfig {
+	return c.GithubApp
+}
+
+func (c Config) GetSigning() *types.SigningConfig {
+	return c.Signing
+}
+
+func (c Config) GetCache() *types.CacheConfig {
+	return c.Cache
+}
+
+func (c Config) GetGitBackend() string {
+	return c.GitBackend
+}
+
 var (
 	// Version will be replaced during build time
 	Version = "dev"
@@ -73,11 +110,18 @@ func Execute() {
 			}
 
 			prCmd.AddCommand(apply.NewCommand(c))
+			prCmd.AddCommand(apply.NewPlanCommand(c))
 			prCmd.AddCommand(status.NewCommand(c))
+			prCmd.AddCommand(diff.NewCommand(c))
+			prCmd.AddCommand(retract.NewCloseCommand(c))
+			prCmd.AddCommand(retract.NewDestroyCommand(c))
+			prCmd.AddCommand(merge.NewCommand(c))
+			prCmd.AddCommand(ready.NewCommand(c))
 			rootCmd.AddCommand(prCmd)
+			rootCmd.AddCommand(cache.NewCommand(c))
 
 			if err := rootCmd.Execute(); err != nil {
-				log.Error("failed to execute command", "err", err)
+				log.Error("failed to execute command", "err", redact.String(err.Error()))
 				os.Exit(1)
 			}
 
@@ -121,11 +165,48 @@ func loadConfig(cfgFile string) (cfg Config, err error) {
 	cfg.GithubToken = viper.GetString("github-token")
 	cfg.AuthorEmail = viper.GetString("author-email")
 	cfg.AuthorName = viper.GetString("author-name")
+	cfg.Repos = viper.GetStringSlice("repo")
+	cfg.GitBackend = viper.GetString("git-backend")
+	if err := viper.UnmarshalKey("providers", &cfg.Providers); err != nil {
+		return cfg, fmt.Errorf("failed to parse providers: %v", err)
+	}
+	for i, p := range cfg.Providers {
+		// e.g. GITLAB_TOKEN for a provider of type gitlab
+		if p.Token == "" {
+			cfg.Providers[i].Token = os.Getenv(strings.ToUpper(strings.ReplaceAll(p.Type, "-", "_")) + "_TOKEN")
+		}
+	}
+
+	if viper.IsSet("github-app") {
+		cfg.GithubApp = &types.GitHubAppConfig{}
+		if err := viper.UnmarshalKey("github-app", cfg.GithubApp); err != nil {
+			return cfg, fmt.Errorf("failed to parse github-app: %v", err)
+		}
+	}
+
+	if viper.IsSet("signing") {
+		cfg.Signing = &types.SigningConfig{}
+		if err := viper.UnmarshalKey("signing", cfg.Signing); err != nil {
+			return cfg, fmt.Errorf("failed to parse signing: %v", err)
+		}
+	}
+
+	if viper.IsSet("cache") {
+		cfg.Cache = &types.CacheConfig{}
+		if err := viper.UnmarshalKey("cache", cfg.Cache); err != nil {
+			return cfg, fmt.Errorf("failed to parse cache: %v", err)
+		}
+	}
+
+	redact.Register(cfg.GithubToken)
+	for _, p := range cfg.Providers {
+		redact.Register(p.Token)
+	}
 
-	log.Debug("config loaded", "config", cfg)
+	log.Debug("config loaded", "author", cfg.AuthorName, "repos", cfg.Repos, "providers", len(cfg.Providers))
 
-	if cfg.GithubToken == "" {
-		return cfg, fmt.Errorf("github token is required but was empty")
+	if cfg.GithubToken == "" && cfg.GithubApp == nil {
+		return cfg, fmt.Errorf("github token or github-app is required but neither was set")
 	}
 	return cfg, nil
 }
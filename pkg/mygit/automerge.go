@@ -0,0 +1,57 @@
+package mygit
+
+import (
+	"context"
+	"fmt"
+)
+
+// AutoMerger is implemented by providers that can merge a pull request on
+// their own once its checks and reviews pass
+type AutoMerger interface {
+	EnableAutoMerge(ctx context.Context, owner, repo string, number int, opts AutoMergeOptions) error
+	AutoMergeEnabled(ctx context.Context, owner, repo string, number int) (bool, error)
+}
+
+// AutoMergeOptions configures the merge performed by the host. Empty fields
+// keep the host defaults.
+type AutoMergeOptions struct {
+	Method         string
+	CommitHeadline string
+}
+
+func (g *Git) autoMerger(repoStr string) (AutoMerger, string, string, error) {
+	host, owner, repo, err := g.ParseRepoString(repoStr)
+	if err != nil {
+		return nil, "", "", err
+	}
+	p, err := g.providerFor(host)
+	if err != nil {
+		return nil, "", "", err
+	}
+	am, ok := p.(AutoMerger)
+	if !ok {
+		return nil, "", "", fmt.Errorf("auto-merge is not supported by the provider of %s", host)
+	}
+	return am, owner, repo, nil
+}
+
+func (g *Git) EnableAutoMerge(ctx context.Context, repoStr string, number int, opts AutoMergeOptions) error {
+	if opts.Method != "" {
+		if err := ValidateMergeMethod(opts.Method); err != nil {
+			return err
+		}
+	}
+	am, owner, repo, err := g.autoMerger(repoStr)
+	if err != nil {
+		return err
+	}
+	return am.EnableAutoMerge(ctx, owner, repo, number, opts)
+}
+
+func (g *Git) AutoMergeEnabled(ctx context.Context, repoStr string, number int) (bool, error) {
+	am, owner, repo, err := g.autoMerger(repoStr)
+	if err != nil {
+		return false, err
+	}
+	return am.AutoMergeEnabled(ctx, owner, repo, number)
+}
@@ -0,0 +1,122 @@
+package mygit
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/nsxbet/proliferate/pkg/types"
+)
+
+// Backend performs the git operations on local clones. Clones are identified
+// by their directory, and remotes by name.
+type Backend interface {
+	Clone(repo string, opts CloneOptions) (string, error)
+	AddRemote(dir string, name string, repo string) error
+	RemoteHead(dir string) (string, error)
+	CreateBranch(dir string, branch string, base string) error
+	FetchRemoteBranch(dir string, remote string, branch string) (string, error)
+	ForeignCommits(dir string, base string, remote string, branch string, ours []string) ([]BranchCommit, error)
+	CherryPick(dir string, commits []BranchCommit) error
+	Diff(dir string) (string, error)
+	DiffRemoteBranch(dir string, remote string, branch string) (string, error)
+	Patch(dir string) (string, error)
+	Add(dir string) error
+	Commit(dir string, message string, opts CommitOptions) error
+	Push(dir string, remote string, branch string, expectedHead string) error
+	GetCommitID(dir string) (string, error)
+	DeleteRemoteBranch(repo string, branch string) error
+}
+
+// BranchCommit is a commit of a PR branch, as listed by ForeignCommits
+type BranchCommit struct {
+	SHA            string
+	Subject        string
+	CommitterName  string
+	CommitterEmail string
+	CommitterDate  time.Time
+	// Merge is set for commits with more than one parent
+	Merge bool
+}
+
+// String describes the commit the way the status records foreign commits
+func (c BranchCommit) String() string {
+	return fmt.Sprintf("%s %s (%s)", c.SHA[:12], c.Subject, c.CommitterEmail)
+}
+
+// execBackend runs the git binary, authenticating through an in-process
+// credential helper
+type execBackend struct {
+	*Git
+}
+
+func newBackend(g *Git, name string) (Backend, error) {
+	switch name {
+	case types.GitBackendExec, "":
+		return &execBackend{Git: g}, nil
+	case types.GitBackendGoGit:
+		return newGoGitBackend(g, nil), nil
+	default:
+		return nil, fmt.Errorf("unknown git backend %q, expected %s or %s", name, types.GitBackendExec, types.GitBackendGoGit)
+	}
+}
+
+// Backend returns the backend running the git operations of g
+func (g *Git) Backend() Backend {
+	return g.backend
+}
+
+func (g *Git) Clone(repo string, opts CloneOptions) (string, error) {
+	return g.backend.Clone(repo, opts)
+}
+
+func (g *Git) AddRemote(dir string, name string, repo string) error {
+	return g.backend.AddRemote(dir, name, repo)
+}
+
+func (g *Git) CreateBranch(dir string, branch string, base string) error {
+	return g.backend.CreateBranch(dir, branch, base)
+}
+
+func (g *Git) FetchRemoteBranch(dir string, remote string, branch string) (string, error) {
+	return g.backend.FetchRemoteBranch(dir, remote, branch)
+}
+
+func (g *Git) ForeignCommits(dir string, base string, remote string, branch string, ours []string) ([]BranchCommit, error) {
+	return g.backend.ForeignCommits(dir, base, remote, branch, ours)
+}
+
+func (g *Git) CherryPick(dir string, commits []BranchCommit) error {
+	return g.backend.CherryPick(dir, commits)
+}
+
+func (g *Git) Diff(dir string) (string, error) {
+	return g.backend.Diff(dir)
+}
+
+func (g *Git) DiffRemoteBranch(dir string, remote string, branch string) (string, error) {
+	return g.backend.DiffRemoteBranch(dir, remote, branch)
+}
+
+func (g *Git) Patch(dir string) (string, error) {
+	return g.backend.Patch(dir)
+}
+
+func (g *Git) Add(dir string) error {
+	return g.backend.Add(dir)
+}
+
+func (g *Git) Commit(dir string, message string, opts CommitOptions) error {
+	return g.backend.Commit(dir, message, opts)
+}
+
+func (g *Git) Push(dir string, remote string, branch string, expectedHead string) error {
+	return g.backend.Push(dir, remote, branch, expectedHead)
+}
+
+func (g *Git) GetCommitID(dir string) (string, error) {
+	return g.backend.GetCommitID(dir)
+}
+
+func (g *Git) DeleteRemoteBranch(repo string, branch string) error {
+	return g.backend.DeleteRemoteBranch(repo, branch)
+}
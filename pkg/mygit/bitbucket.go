@@ -0,0 +1,752 @@
+package mygit
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/nsxbet/proliferate/pkg/types"
+)
+
+// bitbucketCloudProvider talks to bitbucket.org. Owners are workspaces.
+type bitbucketCloudProvider struct {
+	host     string
+	username string
+	token    string
+	api      *restClient
+}
+
+// bitbucketServerProvider talks to Bitbucket Server / Data Center. Owners are project keys.
+type bitbucketServerProvider struct {
+	host     string
+	baseURL  string
+	username string
+	token    string
+	api      *restClient
+	// builds talks to the build status API, which lives outside /rest/api
+	builds *restClient
+}
+
+type bitbucketCloudPullRequest struct {
+	ID          int    `json:"id"`
+	State       string `json:"state"`
+	Draft       bool   `json:"draft"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Links       struct {
+		HTML struct {
+			Href string `json:"href"`
+		} `json:"html"`
+	} `json:"links"`
+}
+
+type bitbucketCloudPage struct {
+	Next string `json:"next"`
+}
+
+type bitbucketServerPullRequest struct {
+	ID          int    `json:"id"`
+	Version     int    `json:"version"`
+	State       string `json:"state"`
+	Draft       bool   `json:"draft"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	ToRef       struct {
+		DisplayID string `json:"displayId"`
+	} `json:"toRef"`
+	Links struct {
+		Self []struct {
+			Href string `json:"href"`
+		} `json:"self"`
+	} `json:"links"`
+}
+
+type bitbucketServerPage struct {
+	IsLastPage    bool `json:"isLastPage"`
+	NextPageStart int  `json:"nextPageStart"`
+}
+
+func newBitbucketCloudProvider(cfg types.ProviderConfig) *bitbucketCloudProvider {
+	baseURL := cfg.BaseURL
+	if baseURL == "" {
+		baseURL = "https://api.bitbucket.org/2.0"
+	}
+
+	return &bitbucketCloudProvider{
+		host:     cfg.Host,
+		username: cfg.Username,
+		token:    cfg.Token,
+		api:      newRestClient(baseURL, bitbucketAuthHeader(cfg.Username, cfg.Token)),
+	}
+}
+
+func newBitbucketServerProvider(cfg types.ProviderConfig) *bitbucketServerProvider {
+	baseURL := strings.TrimSuffix(cfg.BaseURL, "/")
+	if baseURL == "" {
+		baseURL = "https://" + cfg.Host
+	}
+
+	return &bitbucketServerProvider{
+		host:     cfg.Host,
+		baseURL:  baseURL,
+		username: cfg.Username,
+		token:    cfg.Token,
+		api:      newRestClient(baseURL+"/rest/api/1.0", bitbucketAuthHeader("", cfg.Token)),
+		builds:   newRestClient(baseURL+"/rest/build-status/1.0", bitbucketAuthHeader("", cfg.Token)),
+	}
+}
+
+// bitbucketAuthHeader uses basic auth for app passwords and bearer auth for access tokens
+func bitbucketAuthHeader(username, token string) http.Header {
+	header := http.Header{}
+	if username != "" {
+		basic := base64.StdEncoding.EncodeToString([]byte(username + ":" + token))
+		header.Set("Authorization", "Basic "+basic)
+	} else {
+		header.Set("Authorization", "Bearer "+token)
+	}
+	return header
+}
+
+// bitbucketState maps Bitbucket pull request states onto open, closed and merged
+func bitbucketState(state string) string {
+	switch state {
+	case "OPEN":
+		return "open"
+	case "MERGED":
+		return "merged"
+	default:
+		return "closed"
+	}
+}
+
+// bitbucketBuildState maps the build states shared by Bitbucket Cloud and Server onto check states
+func bitbucketBuildState(state string) string {
+	switch state {
+	case "SUCCESSFUL":
+		return ChecksSuccess
+	case "INPROGRESS":
+		return ChecksPending
+	default:
+		return ChecksFailure
+	}
+}
+
+func bitbucketTeamWarnings(teams []string) []string {
+	var warnings []string
+	for _, team := range teams {
+		warnings = append(warnings, fmt.Sprintf("could not request review from team %s: Bitbucket has no team reviewers", team))
+	}
+	return warnings
+}
+
+func bitbucketLabelWarnings(labels []string) []string {
+	if len(labels) == 0 {
+		return nil
+	}
+	return []string{fmt.Sprintf("could not add labels %s: Bitbucket has no labels", strings.Join(labels, ", "))}
+}
+
+func (p *bitbucketCloudProvider) repoPath(owner, repo string) string {
+	return fmt.Sprintf("/repositories/%s/%s", url.PathEscape(owner), url.PathEscape(repo))
+}
+
+func (p *bitbucketCloudProvider) CloneURL(owner, repo string) string {
+	return fmt.Sprintf("https://%s/%s/%s.git", p.host, owner, repo)
+}
+
+func (p *bitbucketCloudProvider) Credentials(ctx context.Context, owner string) (string, string, error) {
+	if p.username != "" {
+		return p.username, p.token, nil
+	}
+	return "x-token-auth", p.token, nil
+}
+
+func (p *bitbucketCloudProvider) GetPRStatus(ctx context.Context, owner, repo string, number int) (string, error) {
+	var pr bitbucketCloudPullRequest
+	path := fmt.Sprintf("%s/pullrequests/%d", p.repoPath(owner, repo), number)
+	if _, err := p.api.do(ctx, http.MethodGet, path, nil, nil, &pr); err != nil {
+		return "", fmt.Errorf("failed to get PR status: %v", err)
+	}
+	return bitbucketState(pr.State), nil
+}
+
+// DescribePR returns the title and body of the pull request, Bitbucket has no
+// labels or assignees to compare
+func (p *bitbucketCloudProvider) DescribePR(ctx context.Context, owner, repo string, number int) (*PRMetadata, error) {
+	var pr bitbucketCloudPullRequest
+	path := fmt.Sprintf("%s/pullrequests/%d", p.repoPath(owner, repo), number)
+	if _, err := p.api.do(ctx, http.MethodGet, path, nil, nil, &pr); err != nil {
+		return nil, fmt.Errorf("failed to get PR: %v", err)
+	}
+	return &PRMetadata{Title: pr.Title, Body: pr.Description, OnlyTitleAndBody: true}, nil
+}
+
+// ClosePR declines the pull request, Bitbucket's equivalent of closing it
+func (p *bitbucketCloudProvider) ClosePR(ctx context.Context, owner, repo string, number int, comment string) error {
+	path := fmt.Sprintf("%s/pullrequests/%d", p.repoPath(owner, repo), number)
+	if comment != "" {
+		body := map[string]interface{}{"content": map[string]string{"raw": comment}}
+		if _, err := p.api.do(ctx, http.MethodPost, path+"/comments", nil, body, nil); err != nil {
+			return fmt.Errorf("failed to comment on PR: %v", err)
+		}
+	}
+	if _, err := p.api.do(ctx, http.MethodPost, path+"/decline", nil, nil, nil); err != nil {
+		return fmt.Errorf("failed to decline PR: %v", err)
+	}
+	return nil
+}
+
+func (p *bitbucketCloudProvider) MergeState(ctx context.Context, owner, repo string, number int) (*MergeState, error) {
+	var pr struct {
+		State  string `json:"state"`
+		Source struct {
+			Commit struct {
+				Hash string `json:"hash"`
+			} `json:"commit"`
+		} `json:"source"`
+		Participants []struct {
+			User struct {
+				UUID string `json:"uuid"`
+			} `json:"user"`
+			Approved bool   `json:"approved"`
+			State    string `json:"state"`
+		} `json:"participants"`
+	}
+	path := fmt.Sprintf("%s/pullrequests/%d", p.repoPath(owner, repo), number)
+	if _, err := p.api.do(ctx, http.MethodGet, path, nil, nil, &pr); err != nil {
+		return nil, fmt.Errorf("failed to get PR: %v", err)
+	}
+	state := &MergeState{State: bitbucketState(pr.State), HeadSHA: pr.Source.Commit.Hash}
+
+	var statuses struct {
+		Values []struct {
+			State string `json:"state"`
+		} `json:"values"`
+	}
+	statusPath := fmt.Sprintf("%s/commit/%s/statuses", p.repoPath(owner, repo), url.PathEscape(state.HeadSHA))
+	if _, err := p.api.do(ctx, http.MethodGet, statusPath, url.Values{"pagelen": {"100"}}, nil, &statuses); err != nil {
+		return nil, fmt.Errorf("failed to get commit statuses: %v", err)
+	}
+	var checks []string
+	for _, s := range statuses.Values {
+		checks = append(checks, bitbucketBuildState(s.State))
+	}
+	state.Checks = combineChecks(checks)
+
+	verdicts := make(map[string]string)
+	for _, participant := range pr.Participants {
+		switch {
+		case participant.Approved:
+			verdicts[participant.User.UUID] = reviewApproved
+		case participant.State == "changes_requested":
+			verdicts[participant.User.UUID] = reviewChangesRequested
+		}
+	}
+	state.Approvals, state.ChangesRequested = tallyReviews(verdicts)
+
+	return state, nil
+}
+
+// MergePR merges the pull request. Bitbucket Cloud cannot pin the head commit,
+// so it is compared right before merging instead.
+func (p *bitbucketCloudProvider) MergePR(ctx context.Context, owner, repo string, number int, method, headSHA string) (string, error) {
+	current, err := p.MergeState(ctx, owner, repo, number)
+	if err != nil {
+		return "", err
+	}
+	if current.HeadSHA != headSHA {
+		return "", fmt.Errorf("PR head moved from %s to %s, not merging", headSHA, current.HeadSHA)
+	}
+
+	strategy := map[string]string{
+		MergeMethodMerge:  "merge_commit",
+		MergeMethodSquash: "squash",
+		MergeMethodRebase: "fast_forward",
+	}[method]
+
+	var pr struct {
+		MergeCommit struct {
+			Hash string `json:"hash"`
+		} `json:"merge_commit"`
+	}
+	path := fmt.Sprintf("%s/pullrequests/%d/merge", p.repoPath(owner, repo), number)
+	if _, err := p.api.do(ctx, http.MethodPost, path, nil, map[string]string{"merge_strategy": strategy}, &pr); err != nil {
+		return "", fmt.Errorf("failed to merge PR: %v", err)
+	}
+	return pr.MergeCommit.Hash, nil
+}
+
+func (p *bitbucketCloudProvider) MarkReady(ctx context.Context, owner, repo string, number int) error {
+	path := fmt.Sprintf("%s/pullrequests/%d", p.repoPath(owner, repo), number)
+	if _, err := p.api.do(ctx, http.MethodPut, path, nil, map[string]bool{"draft": false}, nil); err != nil {
+		return fmt.Errorf("failed to mark PR ready: %v", err)
+	}
+	return nil
+}
+
+func (p *bitbucketCloudProvider) DefaultBranch(ctx context.Context, owner, repo string) (string, error) {
+	var r struct {
+		MainBranch struct {
+			Name string `json:"name"`
+		} `json:"mainbranch"`
+	}
+	if _, err := p.api.do(ctx, http.MethodGet, p.repoPath(owner, repo), nil, nil, &r); err != nil {
+		return "", fmt.Errorf("failed to get repository: %v", err)
+	}
+	return r.MainBranch.Name, nil
+}
+
+// FilterRepositoriesByOrg fetches repositories from a Bitbucket workspace matching a regex pattern
+func (p *bitbucketCloudProvider) FilterRepositoriesByOrg(ctx context.Context, workspace, pattern string) ([]string, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid repository filter pattern: %v", err)
+	}
+
+	var allRepos []string
+	path := "/repositories/" + url.PathEscape(workspace)
+	for page := 1; ; page++ {
+		var result struct {
+			bitbucketCloudPage
+			Values []struct {
+				Slug   string    `json:"slug"`
+				Parent *struct{} `json:"parent"`
+			} `json:"values"`
+		}
+		query := url.Values{"pagelen": {"100"}, "page": {strconv.Itoa(page)}}
+		if _, err := p.api.do(ctx, http.MethodGet, path, query, nil, &result); err != nil {
+			return nil, fmt.Errorf("failed to list repositories: %v", err)
+		}
+
+		for _, repo := range result.Values {
+			// Skip forks
+			if repo.Parent != nil {
+				continue
+			}
+			if re.MatchString(repo.Slug) {
+				allRepos = append(allRepos, fmt.Sprintf("%s/%s/%s", p.host, workspace, repo.Slug))
+			}
+		}
+
+		if result.Next == "" {
+			break
+		}
+	}
+
+	if len(allRepos) == 0 {
+		return nil, fmt.Errorf("no repositories match the filter pattern '%s' in workspace '%s'",
+			pattern, workspace)
+	}
+
+	return allRepos, nil
+}
+
+// rejectedReviewers reports whether Bitbucket refused to save a PR because of
+// its reviewers. Cloud answers 400 and Server 409, naming the reviewers.
+func rejectedReviewers(resp *http.Response, err error) bool {
+	if err == nil || resp == nil {
+		return false
+	}
+	if resp.StatusCode != http.StatusBadRequest && resp.StatusCode != http.StatusConflict {
+		return false
+	}
+	return strings.Contains(strings.ToLower(err.Error()), "reviewer")
+}
+
+// CreatePR opens or updates a pull request. Bitbucket has no assignees, so
+// they are requested as reviewers, identified by account ID or {uuid}.
+func (p *bitbucketCloudProvider) CreatePR(ctx context.Context, owner, repo string, opts PROptions) (*PullRequest, error) {
+	var existing struct {
+		Values []bitbucketCloudPullRequest `json:"values"`
+	}
+	pullsPath := p.repoPath(owner, repo) + "/pullrequests"
+	query := url.Values{
+		"state": {"OPEN"},
+		"q":     {fmt.Sprintf(`source.branch.name = "%s" AND destination.branch.name = "%s"`, opts.Branch, opts.Base)},
+	}
+	if _, err := p.api.do(ctx, http.MethodGet, pullsPath, query, nil, &existing); err != nil {
+		return nil, fmt.Errorf("failed to list PRs: %v", err)
+	}
+
+	reviewers := func(users []string) []map[string]string {
+		reviewers := []map[string]string{}
+		for _, r := range users {
+			if strings.HasPrefix(r, "{") {
+				reviewers = append(reviewers, map[string]string{"uuid": r})
+			} else {
+				reviewers = append(reviewers, map[string]string{"account_id": r})
+			}
+		}
+		return reviewers
+	}
+
+	body := map[string]interface{}{
+		"title":       opts.Title,
+		"description": opts.Body,
+		"reviewers":   reviewers(append(append([]string{}, opts.Assignees...), opts.Reviewers...)),
+	}
+
+	save := func() (bitbucketCloudPullRequest, *http.Response, error) {
+		var pr bitbucketCloudPullRequest
+		if len(existing.Values) > 0 {
+			path := fmt.Sprintf("%s/%d", pullsPath, existing.Values[0].ID)
+			resp, err := p.api.do(ctx, http.MethodPut, path, nil, body, &pr)
+			if err != nil {
+				return pr, resp, fmt.Errorf("failed to update PR: %v", err)
+			}
+			return pr, resp, nil
+		}
+		body["source"] = map[string]interface{}{"branch": map[string]string{"name": opts.Branch}}
+		body["destination"] = map[string]interface{}{"branch": map[string]string{"name": opts.Base}}
+		body["draft"] = opts.Draft
+		resp, err := p.api.do(ctx, http.MethodPost, pullsPath, nil, body, &pr)
+		if err != nil {
+			return pr, resp, fmt.Errorf("failed to create PR: %v", err)
+		}
+		return pr, resp, nil
+	}
+
+	warnings := append(bitbucketTeamWarnings(opts.TeamReviewers), bitbucketLabelWarnings(opts.Labels)...)
+	pr, resp, err := save()
+	if len(opts.Reviewers) > 0 && rejectedReviewers(resp, err) {
+		// Bitbucket rejects the whole PR for one unknown reviewer, retry without them
+		warnings = append(warnings, fmt.Sprintf("could not request review from %s: %v", strings.Join(opts.Reviewers, ", "), err))
+		body["reviewers"] = reviewers(opts.Assignees)
+		pr, _, err = save()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &PullRequest{
+		Number:   pr.ID,
+		URL:      pr.Links.HTML.Href,
+		State:    bitbucketState(pr.State),
+		Draft:    pr.Draft,
+		Warnings: warnings,
+	}, nil
+}
+
+func (p *bitbucketServerProvider) repoPath(project, repo string) string {
+	return fmt.Sprintf("/projects/%s/repos/%s", url.PathEscape(project), url.PathEscape(repo))
+}
+
+func (p *bitbucketServerProvider) CloneURL(project, repo string) string {
+	return fmt.Sprintf("%s/scm/%s/%s.git", p.baseURL, strings.ToLower(project), repo)
+}
+
+func (p *bitbucketServerProvider) Credentials(ctx context.Context, owner string) (string, string, error) {
+	if p.username != "" {
+		return p.username, p.token, nil
+	}
+	return "x-token-auth", p.token, nil
+}
+
+func (p *bitbucketServerProvider) GetPRStatus(ctx context.Context, project, repo string, number int) (string, error) {
+	var pr bitbucketServerPullRequest
+	path := fmt.Sprintf("%s/pull-requests/%d", p.repoPath(project, repo), number)
+	if _, err := p.api.do(ctx, http.MethodGet, path, nil, nil, &pr); err != nil {
+		return "", fmt.Errorf("failed to get PR status: %v", err)
+	}
+	return bitbucketState(pr.State), nil
+}
+
+// DescribePR returns the title and body of the pull request, Bitbucket has no
+// labels or assignees to compare
+func (p *bitbucketServerProvider) DescribePR(ctx context.Context, project, repo string, number int) (*PRMetadata, error) {
+	var pr bitbucketServerPullRequest
+	path := fmt.Sprintf("%s/pull-requests/%d", p.repoPath(project, repo), number)
+	if _, err := p.api.do(ctx, http.MethodGet, path, nil, nil, &pr); err != nil {
+		return nil, fmt.Errorf("failed to get PR: %v", err)
+	}
+	return &PRMetadata{Title: pr.Title, Body: pr.Description, OnlyTitleAndBody: true}, nil
+}
+
+// ClosePR declines the pull request at its current version
+func (p *bitbucketServerProvider) ClosePR(ctx context.Context, project, repo string, number int, comment string) error {
+	path := fmt.Sprintf("%s/pull-requests/%d", p.repoPath(project, repo), number)
+	if comment != "" {
+		if _, err := p.api.do(ctx, http.MethodPost, path+"/comments", nil, map[string]string{"text": comment}, nil); err != nil {
+			return fmt.Errorf("failed to comment on PR: %v", err)
+		}
+	}
+
+	var pr bitbucketServerPullRequest
+	if _, err := p.api.do(ctx, http.MethodGet, path, nil, nil, &pr); err != nil {
+		return fmt.Errorf("failed to get PR: %v", err)
+	}
+	query := url.Values{"version": {strconv.Itoa(pr.Version)}}
+	if _, err := p.api.do(ctx, http.MethodPost, path+"/decline", query, nil, nil); err != nil {
+		return fmt.Errorf("failed to decline PR: %v", err)
+	}
+	return nil
+}
+
+type bitbucketServerMergeView struct {
+	State   string `json:"state"`
+	Version int    `json:"version"`
+	FromRef struct {
+		LatestCommit string `json:"latestCommit"`
+	} `json:"fromRef"`
+	Reviewers []struct {
+		User struct {
+			Name string `json:"name"`
+		} `json:"user"`
+		Approved bool   `json:"approved"`
+		Status   string `json:"status"`
+	} `json:"reviewers"`
+}
+
+func (p *bitbucketServerProvider) MergeState(ctx context.Context, project, repo string, number int) (*MergeState, error) {
+	var pr bitbucketServerMergeView
+	path := fmt.Sprintf("%s/pull-requests/%d", p.repoPath(project, repo), number)
+	if _, err := p.api.do(ctx, http.MethodGet, path, nil, nil, &pr); err != nil {
+		return nil, fmt.Errorf("failed to get PR: %v", err)
+	}
+	state := &MergeState{State: bitbucketState(pr.State), HeadSHA: pr.FromRef.LatestCommit}
+
+	var builds struct {
+		Values []struct {
+			State string `json:"state"`
+		} `json:"values"`
+	}
+	if _, err := p.builds.do(ctx, http.MethodGet, "/commits/"+url.PathEscape(state.HeadSHA), url.Values{"limit": {"100"}}, nil, &builds); err != nil {
+		return nil, fmt.Errorf("failed to get build statuses: %v", err)
+	}
+	var checks []string
+	for _, b := range builds.Values {
+		checks = append(checks, bitbucketBuildState(b.State))
+	}
+	state.Checks = combineChecks(checks)
+
+	verdicts := make(map[string]string)
+	for _, reviewer := range pr.Reviewers {
+		switch {
+		case reviewer.Approved:
+			verdicts[reviewer.User.Name] = reviewApproved
+		case reviewer.Status == "NEEDS_WORK":
+			verdicts[reviewer.User.Name] = reviewChangesRequested
+		}
+	}
+	state.Approvals, state.ChangesRequested = tallyReviews(verdicts)
+
+	return state, nil
+}
+
+// MergePR merges the pull request at the version whose head is headSHA
+func (p *bitbucketServerProvider) MergePR(ctx context.Context, project, repo string, number int, method, headSHA string) (string, error) {
+	var current bitbucketServerMergeView
+	path := fmt.Sprintf("%s/pull-requests/%d", p.repoPath(project, repo), number)
+	if _, err := p.api.do(ctx, http.MethodGet, path, nil, nil, &current); err != nil {
+		return "", fmt.Errorf("failed to get PR: %v", err)
+	}
+	if current.FromRef.LatestCommit != headSHA {
+		return "", fmt.Errorf("PR head moved from %s to %s, not merging", headSHA, current.FromRef.LatestCommit)
+	}
+
+	strategy := map[string]string{
+		MergeMethodMerge:  "no-ff",
+		MergeMethodSquash: "squash",
+		MergeMethodRebase: "rebase-ff-only",
+	}[method]
+
+	var pr struct {
+		Properties struct {
+			MergeCommit struct {
+				ID string `json:"id"`
+			} `json:"mergeCommit"`
+		} `json:"properties"`
+	}
+	query := url.Values{"version": {strconv.Itoa(current.Version)}}
+	if _, err := p.api.do(ctx, http.MethodPost, path+"/merge", query, map[string]string{"strategyId": strategy}, &pr); err != nil {
+		return "", fmt.Errorf("failed to merge PR: %v", err)
+	}
+	return pr.Properties.MergeCommit.ID, nil
+}
+
+// MarkReady sends back the current title, description and reviewers, since an
+// update replaces them
+func (p *bitbucketServerProvider) MarkReady(ctx context.Context, project, repo string, number int) error {
+	var pr struct {
+		Version     int               `json:"version"`
+		Title       string            `json:"title"`
+		Description string            `json:"description"`
+		Draft       bool              `json:"draft"`
+		Reviewers   []json.RawMessage `json:"reviewers"`
+	}
+	path := fmt.Sprintf("%s/pull-requests/%d", p.repoPath(project, repo), number)
+	if _, err := p.api.do(ctx, http.MethodGet, path, nil, nil, &pr); err != nil {
+		return fmt.Errorf("failed to get PR: %v", err)
+	}
+	if !pr.Draft {
+		return nil
+	}
+
+	body := map[string]interface{}{
+		"version":     pr.Version,
+		"title":       pr.Title,
+		"description": pr.Description,
+		"reviewers":   pr.Reviewers,
+		"draft":       false,
+	}
+	if _, err := p.api.do(ctx, http.MethodPut, path, nil, body, nil); err != nil {
+		return fmt.Errorf("failed to mark PR ready: %v", err)
+	}
+	return nil
+}
+
+func (p *bitbucketServerProvider) DefaultBranch(ctx context.Context, project, repo string) (string, error) {
+	var branch struct {
+		DisplayID string `json:"displayId"`
+	}
+	// default-branch replaced branches/default in Bitbucket 8
+	_, err := p.api.do(ctx, http.MethodGet, p.repoPath(project, repo)+"/default-branch", nil, nil, &branch)
+	if err != nil {
+		if _, legacyErr := p.api.do(ctx, http.MethodGet, p.repoPath(project, repo)+"/branches/default", nil, nil, &branch); legacyErr != nil {
+			return "", fmt.Errorf("failed to get default branch: %v", err)
+		}
+	}
+	return branch.DisplayID, nil
+}
+
+// FilterRepositoriesByOrg fetches repositories from a Bitbucket Server project matching a regex pattern
+func (p *bitbucketServerProvider) FilterRepositoriesByOrg(ctx context.Context, project, pattern string) ([]string, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid repository filter pattern: %v", err)
+	}
+
+	var allRepos []string
+	path := "/projects/" + url.PathEscape(project) + "/repos"
+	start := 0
+	for {
+		var result struct {
+			bitbucketServerPage
+			Values []struct {
+				Slug     string    `json:"slug"`
+				Archived bool      `json:"archived"`
+				Origin   *struct{} `json:"origin"`
+			} `json:"values"`
+		}
+		query := url.Values{"limit": {"100"}, "start": {strconv.Itoa(start)}}
+		if _, err := p.api.do(ctx, http.MethodGet, path, query, nil, &result); err != nil {
+			return nil, fmt.Errorf("failed to list repositories: %v", err)
+		}
+
+		for _, repo := range result.Values {
+			// Skip forks, archived repositories
+			if repo.Origin != nil || repo.Archived {
+				continue
+			}
+			if re.MatchString(repo.Slug) {
+				allRepos = append(allRepos, fmt.Sprintf("%s/%s/%s", p.host, project, repo.Slug))
+			}
+		}
+
+		if result.IsLastPage {
+			break
+		}
+		start = result.NextPageStart
+	}
+
+	if len(allRepos) == 0 {
+		return nil, fmt.Errorf("no repositories match the filter pattern '%s' in project '%s'",
+			pattern, project)
+	}
+
+	return allRepos, nil
+}
+
+// CreatePR opens or updates a pull request. Bitbucket has no assignees, so
+// they are requested as reviewers by username.
+func (p *bitbucketServerProvider) CreatePR(ctx context.Context, project, repo string, opts PROptions) (*PullRequest, error) {
+	var existing struct {
+		Values []bitbucketServerPullRequest `json:"values"`
+	}
+	pullsPath := p.repoPath(project, repo) + "/pull-requests"
+	query := url.Values{
+		"state":     {"OPEN"},
+		"direction": {"OUTGOING"},
+		"at":        {"refs/heads/" + opts.Branch},
+		"limit":     {"100"},
+	}
+	if _, err := p.api.do(ctx, http.MethodGet, pullsPath, query, nil, &existing); err != nil {
+		return nil, fmt.Errorf("failed to list PRs: %v", err)
+	}
+
+	var matchingPR *bitbucketServerPullRequest
+	for i, pr := range existing.Values {
+		if pr.ToRef.DisplayID == opts.Base {
+			matchingPR = &existing.Values[i]
+			break
+		}
+	}
+
+	reviewers := func(users []string) []map[string]interface{} {
+		reviewers := []map[string]interface{}{}
+		for _, r := range users {
+			reviewers = append(reviewers, map[string]interface{}{"user": map[string]string{"name": r}})
+		}
+		return reviewers
+	}
+
+	body := map[string]interface{}{
+		"title":       opts.Title,
+		"description": opts.Body,
+		"reviewers":   reviewers(append(append([]string{}, opts.Assignees...), opts.Reviewers...)),
+	}
+
+	save := func() (bitbucketServerPullRequest, *http.Response, error) {
+		var pr bitbucketServerPullRequest
+		if matchingPR != nil {
+			body["version"] = matchingPR.Version
+			body["draft"] = matchingPR.Draft
+			path := fmt.Sprintf("%s/%d", pullsPath, matchingPR.ID)
+			resp, err := p.api.do(ctx, http.MethodPut, path, nil, body, &pr)
+			if err != nil {
+				return pr, resp, fmt.Errorf("failed to update PR: %v", err)
+			}
+			return pr, resp, nil
+		}
+		body["fromRef"] = map[string]string{"id": "refs/heads/" + opts.Branch}
+		body["toRef"] = map[string]string{"id": "refs/heads/" + opts.Base}
+		body["draft"] = opts.Draft
+		resp, err := p.api.do(ctx, http.MethodPost, pullsPath, nil, body, &pr)
+		if err != nil {
+			return pr, resp, fmt.Errorf("failed to create PR: %v", err)
+		}
+		return pr, resp, nil
+	}
+
+	warnings := append(bitbucketTeamWarnings(opts.TeamReviewers), bitbucketLabelWarnings(opts.Labels)...)
+	pr, resp, err := save()
+	if len(opts.Reviewers) > 0 && rejectedReviewers(resp, err) {
+		// Bitbucket rejects the whole PR for one unknown reviewer, retry without them
+		warnings = append(warnings, fmt.Sprintf("could not request review from %s: %v", strings.Join(opts.Reviewers, ", "), err))
+		body["reviewers"] = reviewers(opts.Assignees)
+		pr, _, err = save()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var prURL string
+	if len(pr.Links.Self) > 0 {
+		prURL = pr.Links.Self[0].Href
+	}
+
+	return &PullRequest{
+		Number:   pr.ID,
+		URL:      prURL,
+		State:    bitbucketState(pr.State),
+		Draft:    pr.Draft,
+		Warnings: warnings,
+	}, nil
+}
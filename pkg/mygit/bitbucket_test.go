@@ -0,0 +1,319 @@
+package mygit
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/nsxbet/proliferate/pkg/types"
+)
+
+const (
+	bitbucketCloudPulls  = "/repositories/ws/repo/pullrequests"
+	bitbucketServerPulls = "/rest/api/1.0/projects/PRJ/repos/repo/pull-requests"
+)
+
+func newFakeBitbucketCloud(t *testing.T) (*bitbucketCloudProvider, *fakeAPI) {
+	api := newFakeAPI(t, "Authorization", "Bearer token")
+	return newBitbucketCloudProvider(types.ProviderConfig{Host: "bitbucket.org", BaseURL: api.URL, Token: "token"}), api
+}
+
+func newFakeBitbucketServer(t *testing.T) (*bitbucketServerProvider, *fakeAPI) {
+	api := newFakeAPI(t, "Authorization", "Bearer token")
+	return newBitbucketServerProvider(types.ProviderConfig{Host: "bitbucket.example.com", BaseURL: api.URL, Token: "token"}), api
+}
+
+// rejectReviewersOnce fails the first save of a PR the way Bitbucket rejects
+// an unknown reviewer, and answers later saves with pr
+func rejectReviewersOnce(pr interface{}) func(w http.ResponseWriter, r *http.Request) interface{} {
+	saves := 0
+	return func(w http.ResponseWriter, r *http.Request) interface{} {
+		saves++
+		if saves == 1 {
+			return apiError{http.StatusBadRequest, `{"error":{"message":"reviewers: ghost is not a valid user"}}`}
+		}
+		return pr
+	}
+}
+
+func TestBitbucketCloudCreatePR(t *testing.T) {
+	p, api := newFakeBitbucketCloud(t)
+	api.reply("GET "+bitbucketCloudPulls, map[string]interface{}{"values": []interface{}{}})
+	api.reply("POST "+bitbucketCloudPulls, bitbucketCloudPullRequest{ID: 7, State: "OPEN", Draft: true})
+
+	pr, err := p.CreatePR(context.Background(), "ws", "repo", PROptions{
+		Title:         "Bump deps",
+		Body:          "body",
+		Branch:        "bump",
+		Base:          "main",
+		Labels:        []string{"deps"},
+		Assignees:     []string{"{alice-uuid}"},
+		Reviewers:     []string{"bob-account"},
+		TeamReviewers: []string{"sre"},
+		Draft:         true,
+	})
+	if err != nil {
+		t.Fatalf("CreatePR: %v", err)
+	}
+	if pr.Number != 7 || pr.State != "open" || !pr.Draft {
+		t.Errorf("got PR %d in state %s draft=%v, want 7 open draft", pr.Number, pr.State, pr.Draft)
+	}
+	if len(pr.Warnings) != 2 || !strings.Contains(pr.Warnings[1], "deps") {
+		t.Errorf("got warnings %v, want one for the team and one for the labels", pr.Warnings)
+	}
+
+	// Assignees are requested as reviewers, by {uuid} or account ID
+	want := map[string]interface{}{
+		"title":       "Bump deps",
+		"description": "body",
+		"reviewers": []interface{}{
+			map[string]interface{}{"uuid": "{alice-uuid}"},
+			map[string]interface{}{"account_id": "bob-account"},
+		},
+		"source":      map[string]interface{}{"branch": map[string]interface{}{"name": "bump"}},
+		"destination": map[string]interface{}{"branch": map[string]interface{}{"name": "main"}},
+		"draft":       true,
+	}
+	if got := api.received("POST " + bitbucketCloudPulls); !reflect.DeepEqual(got, want) {
+		t.Errorf("created PR with %v, want %v", got, want)
+	}
+}
+
+func TestBitbucketCloudCreatePRUpdatesExisting(t *testing.T) {
+	p, api := newFakeBitbucketCloud(t)
+	api.handle("GET "+bitbucketCloudPulls, func(w http.ResponseWriter, r *http.Request) interface{} {
+		if q := r.URL.Query().Get("q"); q != `source.branch.name = "bump" AND destination.branch.name = "main"` {
+			t.Errorf("looked up PRs with %q", q)
+		}
+		return map[string]interface{}{"values": []bitbucketCloudPullRequest{{ID: 5, State: "OPEN"}}}
+	})
+	api.reply("PUT "+bitbucketCloudPulls+"/5", bitbucketCloudPullRequest{ID: 5, State: "OPEN"})
+
+	pr, err := p.CreatePR(context.Background(), "ws", "repo", PROptions{Title: "Bump deps", Branch: "bump", Base: "main"})
+	if err != nil {
+		t.Fatalf("CreatePR: %v", err)
+	}
+	if pr.Number != 5 {
+		t.Errorf("got PR %d, want 5", pr.Number)
+	}
+	if api.received("POST "+bitbucketCloudPulls) != nil {
+		t.Error("created a second PR")
+	}
+	if _, ok := api.received("PUT " + bitbucketCloudPulls + "/5")["source"]; ok {
+		t.Error("update sent the source branch")
+	}
+}
+
+func TestBitbucketCloudRetriesWithoutRejectedReviewers(t *testing.T) {
+	p, api := newFakeBitbucketCloud(t)
+	api.reply("GET "+bitbucketCloudPulls, map[string]interface{}{"values": []interface{}{}})
+	api.handle("POST "+bitbucketCloudPulls, rejectReviewersOnce(bitbucketCloudPullRequest{ID: 7, State: "OPEN"}))
+
+	pr, err := p.CreatePR(context.Background(), "ws", "repo", PROptions{
+		Branch:    "bump",
+		Base:      "main",
+		Assignees: []string{"alice"},
+		Reviewers: []string{"ghost"},
+	})
+	if err != nil {
+		t.Fatalf("CreatePR: %v", err)
+	}
+	if len(pr.Warnings) != 1 || !strings.Contains(pr.Warnings[0], "ghost") {
+		t.Errorf("got warnings %v, want one for ghost", pr.Warnings)
+	}
+	// The assignees are still requested
+	want := []interface{}{map[string]interface{}{"account_id": "alice"}}
+	if got := api.received("POST " + bitbucketCloudPulls)["reviewers"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("retried with reviewers %v, want %v", got, want)
+	}
+}
+
+func TestBitbucketCloudDoesNotRetryOtherErrors(t *testing.T) {
+	p, api := newFakeBitbucketCloud(t)
+	api.reply("GET "+bitbucketCloudPulls, map[string]interface{}{"values": []interface{}{}})
+	saves := 0
+	api.handle("POST "+bitbucketCloudPulls, func(w http.ResponseWriter, r *http.Request) interface{} {
+		saves++
+		return apiError{http.StatusBadRequest, `{"error":{"message":"destination branch not found"}}`}
+	})
+
+	_, err := p.CreatePR(context.Background(), "ws", "repo", PROptions{Branch: "bump", Base: "gone", Reviewers: []string{"bob"}})
+	if err == nil {
+		t.Fatal("CreatePR succeeded without a destination branch")
+	}
+	if saves != 1 {
+		t.Errorf("saved the PR %d times, want no retry", saves)
+	}
+}
+
+func TestBitbucketCloudFilterRepositoriesByOrg(t *testing.T) {
+	p, api := newFakeBitbucketCloud(t)
+	type repo struct {
+		Slug   string    `json:"slug"`
+		Parent *struct{} `json:"parent,omitempty"`
+	}
+	pages := [][]repo{
+		{{Slug: "svc-a"}, {Slug: "lib"}},
+		{{Slug: "svc-b"}, {Slug: "svc-fork", Parent: &struct{}{}}},
+	}
+	api.handle("GET /repositories/ws", func(w http.ResponseWriter, r *http.Request) interface{} {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		result := map[string]interface{}{"values": pages[page-1]}
+		if page < len(pages) {
+			result["next"] = fmt.Sprintf("%s/repositories/ws?page=%d", api.URL, page+1)
+		}
+		return result
+	})
+
+	repos, err := p.FilterRepositoriesByOrg(context.Background(), "ws", "^svc-")
+	if err != nil {
+		t.Fatalf("FilterRepositoriesByOrg: %v", err)
+	}
+	if want := []string{"bitbucket.org/ws/svc-a", "bitbucket.org/ws/svc-b"}; !reflect.DeepEqual(repos, want) {
+		t.Errorf("got %v, want %v", repos, want)
+	}
+}
+
+func TestBitbucketServerCreatePR(t *testing.T) {
+	p, api := newFakeBitbucketServer(t)
+	api.reply("GET "+bitbucketServerPulls, map[string]interface{}{"values": []interface{}{}})
+	api.reply("POST "+bitbucketServerPulls, bitbucketServerPullRequest{ID: 7, State: "OPEN"})
+
+	pr, err := p.CreatePR(context.Background(), "PRJ", "repo", PROptions{
+		Title:     "Bump deps",
+		Body:      "body",
+		Branch:    "bump",
+		Base:      "main",
+		Labels:    []string{"deps"},
+		Assignees: []string{"alice"},
+	})
+	if err != nil {
+		t.Fatalf("CreatePR: %v", err)
+	}
+	if pr.Number != 7 || pr.State != "open" {
+		t.Errorf("got PR %d in state %s, want 7 open", pr.Number, pr.State)
+	}
+	if len(pr.Warnings) != 1 || !strings.Contains(pr.Warnings[0], "labels") {
+		t.Errorf("got warnings %v, want one for the labels", pr.Warnings)
+	}
+
+	want := map[string]interface{}{
+		"title":       "Bump deps",
+		"description": "body",
+		"reviewers":   []interface{}{map[string]interface{}{"user": map[string]interface{}{"name": "alice"}}},
+		"fromRef":     map[string]interface{}{"id": "refs/heads/bump"},
+		"toRef":       map[string]interface{}{"id": "refs/heads/main"},
+		"draft":       false,
+	}
+	if got := api.received("POST " + bitbucketServerPulls); !reflect.DeepEqual(got, want) {
+		t.Errorf("created PR with %v, want %v", got, want)
+	}
+}
+
+func TestBitbucketServerCreatePRUpdatesExisting(t *testing.T) {
+	p, api := newFakeBitbucketServer(t)
+	// Only the PR into the base branch is updated
+	toOther := bitbucketServerPullRequest{ID: 4, Version: 1, State: "OPEN"}
+	toOther.ToRef.DisplayID = "release"
+	toMain := bitbucketServerPullRequest{ID: 5, Version: 3, State: "OPEN", Draft: true}
+	toMain.ToRef.DisplayID = "main"
+	api.handle("GET "+bitbucketServerPulls, func(w http.ResponseWriter, r *http.Request) interface{} {
+		if at := r.URL.Query().Get("at"); at != "refs/heads/bump" {
+			t.Errorf("looked up PRs at %q", at)
+		}
+		return map[string]interface{}{"values": []bitbucketServerPullRequest{toOther, toMain}}
+	})
+	api.reply("PUT "+bitbucketServerPulls+"/5", bitbucketServerPullRequest{ID: 5, State: "OPEN", Draft: true})
+
+	pr, err := p.CreatePR(context.Background(), "PRJ", "repo", PROptions{Title: "Bump deps", Branch: "bump", Base: "main"})
+	if err != nil {
+		t.Fatalf("CreatePR: %v", err)
+	}
+	if pr.Number != 5 || !pr.Draft {
+		t.Errorf("got PR %d draft=%v, want 5 draft", pr.Number, pr.Draft)
+	}
+	if api.received("POST "+bitbucketServerPulls) != nil {
+		t.Error("created a second PR")
+	}
+	// The update must name the version it replaces and keep the draft state
+	updated := api.received("PUT " + bitbucketServerPulls + "/5")
+	if updated["version"] != 3.0 || updated["draft"] != true {
+		t.Errorf("updated PR with version %v and draft %v, want 3 and true", updated["version"], updated["draft"])
+	}
+}
+
+func TestBitbucketServerRetriesWithoutRejectedReviewers(t *testing.T) {
+	p, api := newFakeBitbucketServer(t)
+	api.reply("GET "+bitbucketServerPulls, map[string]interface{}{"values": []interface{}{}})
+	api.handle("POST "+bitbucketServerPulls, rejectReviewersOnce(bitbucketServerPullRequest{ID: 7, State: "OPEN"}))
+
+	pr, err := p.CreatePR(context.Background(), "PRJ", "repo", PROptions{Branch: "bump", Base: "main", Reviewers: []string{"ghost"}})
+	if err != nil {
+		t.Fatalf("CreatePR: %v", err)
+	}
+	if len(pr.Warnings) != 1 || !strings.Contains(pr.Warnings[0], "ghost") {
+		t.Errorf("got warnings %v, want one for ghost", pr.Warnings)
+	}
+	if got := api.received("POST " + bitbucketServerPulls)["reviewers"]; !reflect.DeepEqual(got, []interface{}{}) {
+		t.Errorf("retried with reviewers %v, want none", got)
+	}
+}
+
+func TestBitbucketServerFilterRepositoriesByOrg(t *testing.T) {
+	p, api := newFakeBitbucketServer(t)
+	type repo struct {
+		Slug     string    `json:"slug"`
+		Archived bool      `json:"archived,omitempty"`
+		Origin   *struct{} `json:"origin,omitempty"`
+	}
+	var repos []repo
+	for i := 0; i < 100; i++ {
+		repos = append(repos, repo{Slug: fmt.Sprintf("lib-%d", i)})
+	}
+	repos = append(repos,
+		repo{Slug: "svc-a"},
+		repo{Slug: "svc-fork", Origin: &struct{}{}},
+		repo{Slug: "svc-old", Archived: true},
+	)
+	api.handle("GET /rest/api/1.0/projects/PRJ/repos", func(w http.ResponseWriter, r *http.Request) interface{} {
+		start, _ := strconv.Atoi(r.URL.Query().Get("start"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		last := start+limit >= len(repos)
+		return map[string]interface{}{
+			"values":        slice(repos, start, limit),
+			"isLastPage":    last,
+			"nextPageStart": start + limit,
+		}
+	})
+
+	got, err := p.FilterRepositoriesByOrg(context.Background(), "PRJ", "^svc-")
+	if err != nil {
+		t.Fatalf("FilterRepositoriesByOrg: %v", err)
+	}
+	if want := []string{"bitbucket.example.com/PRJ/svc-a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestBitbucketGetPRStatus(t *testing.T) {
+	cloud, cloudAPI := newFakeBitbucketCloud(t)
+	server, serverAPI := newFakeBitbucketServer(t)
+	for number, state := range map[int]string{1: "OPEN", 2: "MERGED", 3: "DECLINED", 4: "SUPERSEDED"} {
+		cloudAPI.reply(fmt.Sprintf("GET %s/%d", bitbucketCloudPulls, number), bitbucketCloudPullRequest{ID: number, State: state})
+		serverAPI.reply(fmt.Sprintf("GET %s/%d", bitbucketServerPulls, number), bitbucketServerPullRequest{ID: number, State: state})
+	}
+
+	want := map[int]string{1: "open", 2: "merged", 3: "closed", 4: "closed"}
+	for number, state := range want {
+		if got, err := cloud.GetPRStatus(context.Background(), "ws", "repo", number); err != nil || got != state {
+			t.Errorf("Cloud PR %d is %q, %v, want %s", number, got, err, state)
+		}
+		if got, err := server.GetPRStatus(context.Background(), "PRJ", "repo", number); err != nil || got != state {
+			t.Errorf("Server PR %d is %q, %v, want %s", number, got, err, state)
+		}
+	}
+}
@@ -0,0 +1,294 @@
+package mygit
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/nsxbet/proliferate/pkg/redact"
+	"github.com/nsxbet/proliferate/pkg/types"
+)
+
+// mirrorCache keeps a bare mirror of the branches of each repository. Clones
+// copy its objects, so they only fetch what changed upstream.
+type mirrorCache struct {
+	dir   string
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+// CacheEntry is a mirror in the clone cache
+type CacheEntry struct {
+	// Repo is the "host/owner/repo" string of the mirror
+	Repo     string
+	Path     string
+	Size     int64
+	LastUsed time.Time
+}
+
+// PruneOptions selects the mirrors removed by PruneCache. Mirrors are removed
+// least recently used first.
+type PruneOptions struct {
+	// MaxSize in bytes the cache is shrunk to, 0 means no limit
+	MaxSize int64
+	// OlderThan removes mirrors not used for this long, 0 keeps them
+	OlderThan time.Duration
+	// All empties the cache
+	All    bool
+	DryRun bool
+}
+
+func newMirrorCache(cfg *types.CacheConfig) (*mirrorCache, error) {
+	if cfg != nil && cfg.Disabled {
+		return nil, nil
+	}
+
+	dir := ""
+	if cfg != nil {
+		dir = cfg.Dir
+	}
+	if dir == "" {
+		userCache, err := os.UserCacheDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to find the user cache directory, set cache.dir: %v", err)
+		}
+		dir = filepath.Join(userCache, "proliferate", "mirrors")
+	}
+
+	return &mirrorCache{dir: dir, locks: make(map[string]*sync.Mutex)}, nil
+}
+
+func (c *mirrorCache) path(repo string) string {
+	return filepath.Join(c.dir, filepath.FromSlash(repo)+".git")
+}
+
+// lock serializes access to a mirror between workers and between processes
+func (c *mirrorCache) lock(path string) (func(), error) {
+	c.mu.Lock()
+	mu, ok := c.locks[path]
+	if !ok {
+		mu = &sync.Mutex{}
+		c.locks[path] = mu
+	}
+	c.mu.Unlock()
+	mu.Lock()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		mu.Unlock()
+		return nil, fmt.Errorf("failed to create cache directory: %v", err)
+	}
+	f, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		mu.Unlock()
+		return nil, fmt.Errorf("failed to open cache lock: %v", err)
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
+		mu.Unlock()
+		return nil, fmt.Errorf("failed to lock cache: %v", err)
+	}
+
+	return func() {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+		mu.Unlock()
+	}, nil
+}
+
+// updateMirror creates or fetches the mirror of repo and returns its path,
+// still locked so it cannot be pruned or replaced while a clone reads it.
+// A mirror that fails to fetch is assumed broken and cloned again.
+func (g *execBackend) updateMirror(repo string, remoteURL string) (string, func(), error) {
+	path := g.cache.path(repo)
+	unlock, err := g.cache.lock(path)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if err := g.refreshMirror(repo, path, remoteURL); err != nil {
+		unlock()
+		return "", nil, err
+	}
+	return path, unlock, nil
+}
+
+func (g *execBackend) refreshMirror(repo string, path string, remoteURL string) error {
+	if _, err := os.Stat(path); err == nil {
+		fetchErr := g.fetchMirror(repo, path, remoteURL)
+		if fetchErr == nil {
+			return touchMirror(path)
+		}
+		if err := os.RemoveAll(path); err != nil {
+			return fmt.Errorf("failed to remove broken mirror after %v: %v", fetchErr, err)
+		}
+	}
+
+	// Only branches are mirrored, --mirror would also copy refs such as the
+	// refs/pull/* of every GitHub pull request
+	cmd, err := g.authenticatedCommand(repo, "clone", "--bare", remoteURL, path)
+	if err != nil {
+		return err
+	}
+	if output, err := cmd.CombinedOutput(); err != nil {
+		os.RemoveAll(path)
+		return fmt.Errorf("failed to mirror repository: %s: %v", redact.Bytes(output), err)
+	}
+
+	refspec := exec.Command("git", "-C", path, "config", "remote.origin.fetch", mirrorRefspec)
+	if output, err := refspec.CombinedOutput(); err != nil {
+		os.RemoveAll(path)
+		return fmt.Errorf("failed to configure mirror: %s: %v", output, err)
+	}
+	return touchMirror(path)
+}
+
+// mirrorRefspec fetches the branches of a mirror, pruning deleted ones
+const mirrorRefspec = "+refs/heads/*:refs/heads/*"
+
+func (g *execBackend) fetchMirror(repo string, path string, remoteURL string) error {
+	// Mirrors made with clone --mirror hold every ref, replace them
+	fetch := exec.Command("git", "-C", path, "config", "--get-all", "remote.origin.fetch")
+	if output, err := fetch.Output(); err != nil || strings.TrimSpace(string(output)) != mirrorRefspec {
+		return fmt.Errorf("mirror does not only fetch branches")
+	}
+
+	// The clone host may have changed since the mirror was made
+	setURL := exec.Command("git", "-C", path, "remote", "set-url", "origin", remoteURL)
+	if output, err := setURL.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to update mirror remote: %s: %v", output, err)
+	}
+
+	cmd, err := g.authenticatedCommand(repo, "-C", path, "fetch", "--prune", "origin")
+	if err != nil {
+		return err
+	}
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to fetch mirror: %s: %v", redact.Bytes(output), err)
+	}
+	return nil
+}
+
+// touchMirror records when a mirror was last used, for pruning
+func touchMirror(path string) error {
+	now := time.Now()
+	if err := os.Chtimes(path, now, now); err != nil {
+		return fmt.Errorf("failed to update mirror time: %v", err)
+	}
+	return nil
+}
+
+// CacheEntries lists the mirrors in the clone cache
+func (g *Git) CacheEntries() ([]CacheEntry, error) {
+	if g.cache == nil {
+		return nil, nil
+	}
+
+	var entries []CacheEntry
+	err := filepath.WalkDir(g.cache.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == g.cache.dir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if !d.IsDir() || !strings.HasSuffix(path, ".git") {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size, err := dirSize(path)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(g.cache.dir, strings.TrimSuffix(path, ".git"))
+		entries = append(entries, CacheEntry{
+			Repo:     filepath.ToSlash(rel),
+			Path:     path,
+			Size:     size,
+			LastUsed: info.ModTime(),
+		})
+		return filepath.SkipDir
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache: %v", err)
+	}
+	return entries, nil
+}
+
+// PruneCache removes mirrors selected by opts and returns them
+func (g *Git) PruneCache(opts PruneOptions) ([]CacheEntry, error) {
+	entries, err := g.CacheEntries()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].LastUsed.After(entries[j].LastUsed)
+	})
+
+	var total int64
+	for _, entry := range entries {
+		total += entry.Size
+	}
+
+	// Walk from the least recently used mirror
+	var pruned []CacheEntry
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		expired := opts.OlderThan > 0 && time.Since(entry.LastUsed) > opts.OlderThan
+		oversized := opts.MaxSize > 0 && total > opts.MaxSize
+		if !opts.All && !expired && !oversized {
+			continue
+		}
+
+		if !opts.DryRun {
+			if err := g.removeMirror(entry.Path); err != nil {
+				return pruned, err
+			}
+		}
+		total -= entry.Size
+		pruned = append(pruned, entry)
+	}
+	return pruned, nil
+}
+
+func (g *Git) removeMirror(path string) error {
+	unlock, err := g.cache.lock(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := os.RemoveAll(path); err != nil {
+		return fmt.Errorf("failed to remove mirror %s: %v", path, err)
+	}
+	return nil
+}
+
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	return size, err
+}
@@ -0,0 +1,129 @@
+package mygit
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/nsxbet/proliferate/pkg/redact"
+)
+
+// CloneOptions bounds what a clone fetches and checks out, for large monorepos
+type CloneOptions struct {
+	// Branch is checked out instead of the default branch. With Depth it is
+	// the only branch fetched.
+	Branch string
+	// Depth limits the history to this many commits, 0 fetches everything
+	Depth int
+	// Filter is a partial clone filter such as blob:none
+	Filter string
+	// SparsePaths limits the working tree to these directories
+	SparsePaths []string
+}
+
+// Shallow clones fetch the PR branch this deep and deepen both branches by
+// this much until they meet, giving up and unshallowing after maxDeepen tries
+const (
+	shallowFetchDepth = 50
+	maxDeepen         = 5
+)
+
+func (o CloneOptions) cloneArgs() []string {
+	var args []string
+	if o.Branch != "" {
+		args = append(args, "--branch", o.Branch)
+	}
+	if o.Depth > 0 {
+		args = append(args, "--depth", strconv.Itoa(o.Depth))
+	}
+	if o.Filter != "" {
+		args = append(args, "--filter", o.Filter)
+	}
+	if len(o.SparsePaths) > 0 {
+		args = append(args, "--sparse")
+	}
+	return args
+}
+
+// checkoutCommand builds an authenticated git command inside a clone, for
+// commands that may lazily fetch missing objects of a partial clone
+func (g *execBackend) checkoutCommand(dir string, args ...string) (*exec.Cmd, error) {
+	repo, err := g.clonedRepo(dir)
+	if err != nil {
+		return nil, err
+	}
+	return g.authenticatedCommand(repo, append([]string{"-C", dir}, args...)...)
+}
+
+// stderr returns what a failed git command printed on stderr, redacted and
+// ready to prefix its error
+func stderr(err error) string {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || len(exitErr.Stderr) == 0 {
+		return ""
+	}
+	return strings.TrimSpace(string(redact.Bytes(exitErr.Stderr))) + ": "
+}
+
+func (g *execBackend) setSparsePaths(dir string, paths []string) error {
+	cmd, err := g.checkoutCommand(dir, append([]string{"sparse-checkout", "set", "--"}, paths...)...)
+	if err != nil {
+		return err
+	}
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to set sparse checkout paths: %s: %v", redact.Bytes(output), err)
+	}
+	return nil
+}
+
+func isShallow(dir string) (bool, error) {
+	cmd := exec.Command("git", "-C", dir, "rev-parse", "--is-shallow-repository")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return false, fmt.Errorf("failed to check for a shallow clone: %s: %v", output, err)
+	}
+	return strings.TrimSpace(string(output)) == "true", nil
+}
+
+// deepenToMergeBase fetches history until base and branch share a commit, so
+// that base..branch only lists the commits of the branch
+func (g *execBackend) deepenToMergeBase(dir string, base string, remote string, branch string) error {
+	refs := []struct{ remote, branch string }{{"origin", base}, {remote, branch}}
+
+	for i := 0; ; i++ {
+		mergeBase := exec.Command("git", "-C", dir, "merge-base", "origin/"+base, remote+"/"+branch)
+		if mergeBase.Run() == nil {
+			return nil
+		}
+
+		if i > maxDeepen {
+			return fmt.Errorf("branches %s and %s share no history", base, branch)
+		}
+
+		for j, ref := range refs {
+			args := []string{"-C", dir, "fetch", fmt.Sprintf("--deepen=%d", shallowFetchDepth)}
+			if i == maxDeepen {
+				// Once the first fetch unshallows the clone, the next one fetches everything anyway
+				args = []string{"-C", dir, "fetch"}
+				if j == 0 {
+					args = append(args, "--unshallow")
+				}
+			}
+
+			repo, err := g.remoteRepo(dir, ref.remote)
+			if err != nil {
+				return err
+			}
+			refspec := fmt.Sprintf("+refs/heads/%s:refs/remotes/%s/%s", ref.branch, ref.remote, ref.branch)
+			cmd, err := g.authenticatedCommand(repo, append(args, ref.remote, refspec)...)
+			if err != nil {
+				return err
+			}
+			if output, err := cmd.CombinedOutput(); err != nil {
+				return fmt.Errorf("failed to fetch more history: %s: %v", redact.Bytes(output), err)
+			}
+		}
+	}
+}
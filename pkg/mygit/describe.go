@@ -0,0 +1,36 @@
+package mygit
+
+import (
+	"context"
+	"fmt"
+)
+
+// PRMetadata is the part of an existing pull request proliferate manages
+type PRMetadata struct {
+	Title     string
+	Body      string
+	Labels    []string
+	Assignees []string
+	// OnlyTitleAndBody is set by providers without labels or assignees, whose
+	// pull requests have nothing else to compare
+	OnlyTitleAndBody bool
+}
+
+// PRDescriber is implemented by providers that can read back the metadata of
+// a pull request, so plans can show how applying would change it
+type PRDescriber interface {
+	DescribePR(ctx context.Context, owner, repo string, number int) (*PRMetadata, error)
+}
+
+// DescribePR returns the metadata of pull request number of repoStr
+func (g *Git) DescribePR(ctx context.Context, repoStr string, number int) (*PRMetadata, error) {
+	p, owner, repo, err := g.resolve(repoStr)
+	if err != nil {
+		return nil, err
+	}
+	d, ok := p.(PRDescriber)
+	if !ok {
+		return nil, fmt.Errorf("reading pull requests is not supported by the provider of %s", repoStr)
+	}
+	return d.DescribePR(ctx, owner, repo, number)
+}
@@ -0,0 +1,27 @@
+package mygit
+
+import (
+	"context"
+	"regexp"
+	"strings"
+)
+
+// draftTitlePrefix matches the title prefixes GitLab and Gitea use to mark
+// work in progress pull requests
+var draftTitlePrefix = regexp.MustCompile(`(?i)^\s*(draft:|\[draft\]|\(draft\)|wip:|\[wip\])\s*`)
+
+func hasDraftPrefix(title string) bool {
+	return draftTitlePrefix.MatchString(title)
+}
+
+func stripDraftPrefix(title string) string {
+	return strings.TrimSpace(draftTitlePrefix.ReplaceAllString(title, ""))
+}
+
+func (g *Git) MarkReady(ctx context.Context, repoStr string, number int) error {
+	p, owner, repo, err := g.resolve(repoStr)
+	if err != nil {
+		return err
+	}
+	return p.MarkReady(ctx, owner, repo, number)
+}
@@ -0,0 +1,102 @@
+package mygit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// fakeAPI is a JSON API for provider tests. Routes are keyed by method and
+// escaped path, like "GET /projects/group%2Frepo", and the JSON bodies they
+// receive are recorded.
+type fakeAPI struct {
+	t      *testing.T
+	URL    string
+	mu     sync.Mutex
+	routes map[string]func(w http.ResponseWriter, r *http.Request) interface{}
+	bodies map[string]map[string]interface{}
+}
+
+// apiError makes a route answer with an error status
+type apiError struct {
+	status  int
+	message string
+}
+
+// newFakeAPI starts a fake API that requires the header to hold value
+func newFakeAPI(t *testing.T, header, value string) *fakeAPI {
+	f := &fakeAPI{
+		t:      t,
+		routes: make(map[string]func(w http.ResponseWriter, r *http.Request) interface{}),
+		bodies: make(map[string]map[string]interface{}),
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(header) != value {
+			http.Error(w, `{"message":"unauthorized"}`, http.StatusUnauthorized)
+			return
+		}
+		f.serve(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	f.URL = srv.URL
+	return f
+}
+
+// handle answers route with the JSON encoding of what respond returns
+func (f *fakeAPI) handle(route string, respond func(w http.ResponseWriter, r *http.Request) interface{}) {
+	f.routes[route] = respond
+}
+
+// reply answers route with a fixed value
+func (f *fakeAPI) reply(route string, v interface{}) {
+	f.handle(route, func(http.ResponseWriter, *http.Request) interface{} { return v })
+}
+
+// received returns the last JSON body sent to route, nil when there was none
+func (f *fakeAPI) received(route string) map[string]interface{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.bodies[route]
+}
+
+func (f *fakeAPI) serve(w http.ResponseWriter, r *http.Request) {
+	route := r.Method + " " + r.URL.EscapedPath()
+	respond, ok := f.routes[route]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	if r.ContentLength != 0 && r.Body != nil {
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err == nil {
+			f.mu.Lock()
+			f.bodies[route] = body
+			f.mu.Unlock()
+		}
+	}
+
+	v := respond(w, r)
+	if e, ok := v.(apiError); ok {
+		http.Error(w, e.message, e.status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// slice returns up to limit items of a slice from start on, for paginated lists
+func slice(items interface{}, start, limit int) interface{} {
+	v := reflect.ValueOf(items)
+	end := start + limit
+	if start > v.Len() {
+		start = v.Len()
+	}
+	if end > v.Len() {
+		end = v.Len()
+	}
+	return v.Slice(start, end).Interface()
+}
@@ -0,0 +1,81 @@
+package mygit
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Forker is implemented by providers that can fork repositories, for pull
+// requests against repositories proliferate cannot push to
+type Forker interface {
+	// Fork returns the owner and name of the fork of owner/repo under
+	// forkOwner, creating it when needed. An empty forkOwner forks into the
+	// authenticated account.
+	Fork(ctx context.Context, owner, repo, forkOwner string) (string, string, error)
+	// DeleteFork deletes a repository, refusing anything that is not a fork
+	DeleteFork(ctx context.Context, owner, repo string) error
+}
+
+// Forks are created in the background by some hosts
+const (
+	forkPollAttempts = 30
+	forkPollInterval = 2 * time.Second
+)
+
+func (g *Git) forker(host string) (Forker, error) {
+	p, err := g.providerFor(host)
+	if err != nil {
+		return nil, err
+	}
+	f, ok := p.(Forker)
+	if !ok {
+		return nil, fmt.Errorf("forks are not supported by the provider of %s", host)
+	}
+	return f, nil
+}
+
+// Fork returns the "host/owner/repo" string of the fork of repoStr under forkOwner
+func (g *Git) Fork(ctx context.Context, repoStr string, forkOwner string) (string, error) {
+	host, owner, repo, err := g.ParseRepoString(repoStr)
+	if err != nil {
+		return "", err
+	}
+	f, err := g.forker(host)
+	if err != nil {
+		return "", err
+	}
+
+	forkOwner, forkRepo, err := f.Fork(ctx, owner, repo, forkOwner)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s/%s/%s", host, forkOwner, forkRepo), nil
+}
+
+func (g *Git) DeleteFork(ctx context.Context, forkStr string) error {
+	host, owner, repo, err := g.ParseRepoString(forkStr)
+	if err != nil {
+		return err
+	}
+	f, err := g.forker(host)
+	if err != nil {
+		return err
+	}
+	return f.DeleteFork(ctx, owner, repo)
+}
+
+// waitForFork polls exists until the fork shows up or the attempts run out
+func waitForFork(ctx context.Context, name string, exists func() bool) error {
+	for i := 0; i < forkPollAttempts; i++ {
+		if exists() {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(forkPollInterval):
+		}
+	}
+	return fmt.Errorf("fork %s was not ready in time", name)
+}
@@ -0,0 +1,521 @@
+package mygit
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/nsxbet/proliferate/pkg/types"
+)
+
+const giteaPageSize = 50
+
+type giteaProvider struct {
+	host    string
+	baseURL string
+	token   string
+	api     *restClient
+}
+
+type giteaPullRequest struct {
+	Number  int    `json:"number"`
+	Title   string `json:"title"`
+	Body    string `json:"body"`
+	HTMLURL string `json:"html_url"`
+	State   string `json:"state"`
+	Merged  bool   `json:"merged"`
+	// MergeCommitSHA is only set once the PR is merged
+	MergeCommitSHA string `json:"merge_commit_sha"`
+	Head           struct {
+		Ref  string `json:"ref"`
+		SHA  string `json:"sha"`
+		Repo *struct {
+			Owner struct {
+				Login string `json:"login"`
+			} `json:"owner"`
+		} `json:"repo"`
+	} `json:"head"`
+	Base struct {
+		Ref string `json:"ref"`
+	} `json:"base"`
+	Labels    []giteaLabel `json:"labels"`
+	Assignees []struct {
+		Login string `json:"login"`
+	} `json:"assignees"`
+}
+
+type giteaRepository struct {
+	Name     string `json:"name"`
+	Fork     bool   `json:"fork"`
+	Archived bool   `json:"archived"`
+	Parent   *struct {
+		FullName string `json:"full_name"`
+	} `json:"parent"`
+}
+
+type giteaLabel struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+// newGiteaProvider also serves Forgejo, which keeps the Gitea API.
+// base-url is the web root of the instance, e.g. https://git.example.com
+func newGiteaProvider(cfg types.ProviderConfig) *giteaProvider {
+	baseURL := strings.TrimSuffix(cfg.BaseURL, "/")
+	if baseURL == "" {
+		baseURL = "https://" + cfg.Host
+	}
+
+	header := http.Header{}
+	header.Set("Authorization", "token "+cfg.Token)
+
+	return &giteaProvider{
+		host:    cfg.Host,
+		baseURL: baseURL,
+		token:   cfg.Token,
+		api:     newRestClient(baseURL+"/api/v1", header),
+	}
+}
+
+func (p *giteaProvider) repoPath(owner, repo string) string {
+	return fmt.Sprintf("/repos/%s/%s", url.PathEscape(owner), url.PathEscape(repo))
+}
+
+func (p *giteaProvider) CloneURL(owner, repo string) string {
+	return fmt.Sprintf("%s/%s/%s.git", p.baseURL, owner, repo)
+}
+
+// Credentials uses the token as password, which Gitea accepts with any username
+func (p *giteaProvider) Credentials(ctx context.Context, owner string) (string, string, error) {
+	return "oauth2", p.token, nil
+}
+
+func (p *giteaProvider) DescribePR(ctx context.Context, owner, repo string, number int) (*PRMetadata, error) {
+	var pr giteaPullRequest
+	path := fmt.Sprintf("%s/pulls/%d", p.repoPath(owner, repo), number)
+	if _, err := p.api.do(ctx, http.MethodGet, path, nil, nil, &pr); err != nil {
+		return nil, fmt.Errorf("failed to get PR: %v", err)
+	}
+
+	metadata := &PRMetadata{Title: pr.Title, Body: pr.Body}
+	for _, label := range pr.Labels {
+		metadata.Labels = append(metadata.Labels, label.Name)
+	}
+	for _, assignee := range pr.Assignees {
+		metadata.Assignees = append(metadata.Assignees, assignee.Login)
+	}
+	return metadata, nil
+}
+
+func (p *giteaProvider) GetPRStatus(ctx context.Context, owner, repo string, number int) (string, error) {
+	var pr giteaPullRequest
+	path := fmt.Sprintf("%s/pulls/%d", p.repoPath(owner, repo), number)
+	if _, err := p.api.do(ctx, http.MethodGet, path, nil, nil, &pr); err != nil {
+		return "", fmt.Errorf("failed to get PR status: %v", err)
+	}
+	return giteaState(pr), nil
+}
+
+func giteaState(pr giteaPullRequest) string {
+	if pr.Merged {
+		return "merged"
+	}
+	return pr.State
+}
+
+func (p *giteaProvider) ClosePR(ctx context.Context, owner, repo string, number int, comment string) error {
+	if comment != "" {
+		path := fmt.Sprintf("%s/issues/%d/comments", p.repoPath(owner, repo), number)
+		if _, err := p.api.do(ctx, http.MethodPost, path, nil, map[string]string{"body": comment}, nil); err != nil {
+			return fmt.Errorf("failed to comment on PR: %v", err)
+		}
+	}
+	path := fmt.Sprintf("%s/pulls/%d", p.repoPath(owner, repo), number)
+	if _, err := p.api.do(ctx, http.MethodPatch, path, nil, map[string]string{"state": "closed"}, nil); err != nil {
+		return fmt.Errorf("failed to close PR: %v", err)
+	}
+	return nil
+}
+
+func (p *giteaProvider) MergeState(ctx context.Context, owner, repo string, number int) (*MergeState, error) {
+	var pr giteaPullRequest
+	path := fmt.Sprintf("%s/pulls/%d", p.repoPath(owner, repo), number)
+	if _, err := p.api.do(ctx, http.MethodGet, path, nil, nil, &pr); err != nil {
+		return nil, fmt.Errorf("failed to get PR: %v", err)
+	}
+	state := &MergeState{State: giteaState(pr), HeadSHA: pr.Head.SHA}
+
+	var combined struct {
+		Statuses []struct {
+			Status string `json:"status"`
+		} `json:"statuses"`
+	}
+	statusPath := fmt.Sprintf("%s/commits/%s/status", p.repoPath(owner, repo), url.PathEscape(pr.Head.SHA))
+	if _, err := p.api.do(ctx, http.MethodGet, statusPath, nil, nil, &combined); err != nil {
+		return nil, fmt.Errorf("failed to get commit status: %v", err)
+	}
+	var checks []string
+	for _, s := range combined.Statuses {
+		switch s.Status {
+		case "success", "warning":
+			checks = append(checks, ChecksSuccess)
+		case "pending":
+			checks = append(checks, ChecksPending)
+		default:
+			checks = append(checks, ChecksFailure)
+		}
+	}
+	state.Checks = combineChecks(checks)
+
+	var reviews []struct {
+		User struct {
+			Login string `json:"login"`
+		} `json:"user"`
+		State     string `json:"state"`
+		Dismissed bool   `json:"dismissed"`
+		Stale     bool   `json:"stale"`
+	}
+	if _, err := p.api.do(ctx, http.MethodGet, path+"/reviews", nil, nil, &reviews); err != nil {
+		return nil, fmt.Errorf("failed to list reviews: %v", err)
+	}
+	verdicts := make(map[string]string)
+	for _, review := range reviews {
+		if review.Dismissed || review.Stale {
+			continue
+		}
+		switch review.State {
+		case "APPROVED":
+			verdicts[review.User.Login] = reviewApproved
+		case "REQUEST_CHANGES":
+			verdicts[review.User.Login] = reviewChangesRequested
+		}
+	}
+	state.Approvals, state.ChangesRequested = tallyReviews(verdicts)
+
+	return state, nil
+}
+
+func (p *giteaProvider) MergePR(ctx context.Context, owner, repo string, number int, method, headSHA string) (string, error) {
+	path := fmt.Sprintf("%s/pulls/%d", p.repoPath(owner, repo), number)
+	body := map[string]interface{}{
+		"Do":             method,
+		"head_commit_id": headSHA,
+	}
+	if _, err := p.api.do(ctx, http.MethodPost, path+"/merge", nil, body, nil); err != nil {
+		return "", fmt.Errorf("failed to merge PR: %v", err)
+	}
+
+	// The merge endpoint has no response body
+	var pr giteaPullRequest
+	if _, err := p.api.do(ctx, http.MethodGet, path, nil, nil, &pr); err != nil {
+		return "", fmt.Errorf("failed to get merged PR: %v", err)
+	}
+	return pr.MergeCommitSHA, nil
+}
+
+// MarkReady removes the work in progress prefix from the PR title
+func (p *giteaProvider) MarkReady(ctx context.Context, owner, repo string, number int) error {
+	var pr giteaPullRequest
+	path := fmt.Sprintf("%s/pulls/%d", p.repoPath(owner, repo), number)
+	if _, err := p.api.do(ctx, http.MethodGet, path, nil, nil, &pr); err != nil {
+		return fmt.Errorf("failed to get PR: %v", err)
+	}
+	if !hasDraftPrefix(pr.Title) {
+		return nil
+	}
+
+	body := map[string]string{"title": stripDraftPrefix(pr.Title)}
+	if _, err := p.api.do(ctx, http.MethodPatch, path, nil, body, nil); err != nil {
+		return fmt.Errorf("failed to mark PR ready: %v", err)
+	}
+	return nil
+}
+
+func (p *giteaProvider) DefaultBranch(ctx context.Context, owner, repo string) (string, error) {
+	var r struct {
+		DefaultBranch string `json:"default_branch"`
+	}
+	if _, err := p.api.do(ctx, http.MethodGet, p.repoPath(owner, repo), nil, nil, &r); err != nil {
+		return "", fmt.Errorf("failed to get repository: %v", err)
+	}
+	return r.DefaultBranch, nil
+}
+
+// FilterRepositoriesByOrg fetches repositories from a Gitea organization matching a regex pattern
+func (p *giteaProvider) FilterRepositoriesByOrg(ctx context.Context, org, pattern string) ([]string, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid repository filter pattern: %v", err)
+	}
+
+	var allRepos []string
+	path := "/orgs/" + url.PathEscape(org) + "/repos"
+	for page := 1; ; page++ {
+		var repos []giteaRepository
+		query := url.Values{
+			"page":  {strconv.Itoa(page)},
+			"limit": {strconv.Itoa(giteaPageSize)},
+		}
+		if _, err := p.api.do(ctx, http.MethodGet, path, query, nil, &repos); err != nil {
+			return nil, fmt.Errorf("failed to list repositories: %v", err)
+		}
+
+		for _, repo := range repos {
+			// Skip forks, archived repositories
+			if repo.Fork || repo.Archived {
+				continue
+			}
+			if re.MatchString(repo.Name) {
+				allRepos = append(allRepos, fmt.Sprintf("%s/%s/%s", p.host, org, repo.Name))
+			}
+		}
+
+		if len(repos) < giteaPageSize {
+			break
+		}
+	}
+
+	if len(allRepos) == 0 {
+		return nil, fmt.Errorf("no repositories match the filter pattern '%s' in organization '%s'",
+			pattern, org)
+	}
+
+	return allRepos, nil
+}
+
+func (p *giteaProvider) CreatePR(ctx context.Context, owner, repo string, opts PROptions) (*PullRequest, error) {
+	existing, err := p.findOpenPR(ctx, owner, repo, opts.HeadOwner, opts.Branch, opts.Base)
+	if err != nil {
+		return nil, err
+	}
+
+	assignees := opts.Assignees
+	if assignees == nil {
+		assignees = []string{}
+	}
+
+	var pr giteaPullRequest
+	pullsPath := p.repoPath(owner, repo) + "/pulls"
+	title := opts.Title
+	if existing != nil {
+		// Gitea keeps the draft state in the title
+		if hasDraftPrefix(existing.Title) {
+			title = "WIP: " + opts.Title
+		}
+		body := map[string]interface{}{
+			"title":     title,
+			"body":      opts.Body,
+			"assignees": assignees,
+		}
+		path := fmt.Sprintf("%s/%d", pullsPath, existing.Number)
+		if _, err := p.api.do(ctx, http.MethodPatch, path, nil, body, &pr); err != nil {
+			return nil, fmt.Errorf("failed to update PR: %v", err)
+		}
+	} else {
+		if opts.Draft {
+			title = "WIP: " + opts.Title
+		}
+		head := opts.Branch
+		if opts.HeadOwner != "" {
+			head = opts.HeadOwner + ":" + opts.Branch
+		}
+		body := map[string]interface{}{
+			"head":      head,
+			"base":      opts.Base,
+			"title":     title,
+			"body":      opts.Body,
+			"assignees": assignees,
+		}
+		if _, err := p.api.do(ctx, http.MethodPost, pullsPath, nil, body, &pr); err != nil {
+			return nil, fmt.Errorf("failed to create PR: %v", err)
+		}
+	}
+
+	if err := p.replaceLabels(ctx, owner, repo, pr.Number, opts.Labels); err != nil {
+		return nil, err
+	}
+
+	return &PullRequest{
+		Number:   pr.Number,
+		URL:      pr.HTMLURL,
+		State:    giteaState(pr),
+		Draft:    hasDraftPrefix(pr.Title),
+		Warnings: p.requestReviewers(ctx, owner, repo, pr.Number, opts.Reviewers, opts.TeamReviewers),
+	}, nil
+}
+
+// requestReviewers requests reviews, retrying one reviewer at a time when the
+// batch is rejected so that only the invalid reviewers end up as warnings
+func (p *giteaProvider) requestReviewers(ctx context.Context, owner, repo string, number int, reviewers, teams []string) []string {
+	if len(reviewers) == 0 && len(teams) == 0 {
+		return nil
+	}
+
+	path := fmt.Sprintf("%s/pulls/%d/requested_reviewers", p.repoPath(owner, repo), number)
+	request := func(reviewers, teams []string) error {
+		body := map[string]interface{}{"reviewers": reviewers, "team_reviewers": teams}
+		_, err := p.api.do(ctx, http.MethodPost, path, nil, body, nil)
+		return err
+	}
+	if err := request(reviewers, teams); err == nil {
+		return nil
+	}
+
+	var warnings []string
+	for _, reviewer := range reviewers {
+		if err := request([]string{reviewer}, []string{}); err != nil {
+			warnings = append(warnings, fmt.Sprintf("could not request review from %s: %v", reviewer, err))
+		}
+	}
+	for _, team := range teams {
+		if err := request([]string{}, []string{team}); err != nil {
+			warnings = append(warnings, fmt.Sprintf("could not request review from team %s: %v", team, err))
+		}
+	}
+	return warnings
+}
+
+// findOpenPR finds the open PR from branch into base. A headOwner only matches
+// PRs opened from the fork of that owner.
+func (p *giteaProvider) findOpenPR(ctx context.Context, owner, repo, headOwner, branch, base string) (*giteaPullRequest, error) {
+	path := p.repoPath(owner, repo) + "/pulls"
+	for page := 1; ; page++ {
+		var prs []giteaPullRequest
+		query := url.Values{
+			"state": {"open"},
+			"page":  {strconv.Itoa(page)},
+			"limit": {strconv.Itoa(giteaPageSize)},
+		}
+		if _, err := p.api.do(ctx, http.MethodGet, path, query, nil, &prs); err != nil {
+			return nil, fmt.Errorf("failed to list PRs: %v", err)
+		}
+
+		for _, pr := range prs {
+			if pr.Head.Ref != branch || pr.Base.Ref != base {
+				continue
+			}
+			if headOwner == "" || (pr.Head.Repo != nil && pr.Head.Repo.Owner.Login == headOwner) {
+				return &pr, nil
+			}
+		}
+
+		if len(prs) < giteaPageSize {
+			return nil, nil
+		}
+	}
+}
+
+// replaceLabels maps label names to the repository label IDs Gitea expects
+func (p *giteaProvider) replaceLabels(ctx context.Context, owner, repo string, number int, labels []string) error {
+	ids := map[string]int64{}
+	labelsPath := p.repoPath(owner, repo) + "/labels"
+	for page := 1; ; page++ {
+		var repoLabels []giteaLabel
+		query := url.Values{
+			"page":  {strconv.Itoa(page)},
+			"limit": {strconv.Itoa(giteaPageSize)},
+		}
+		if _, err := p.api.do(ctx, http.MethodGet, labelsPath, query, nil, &repoLabels); err != nil {
+			return fmt.Errorf("failed to list labels: %v", err)
+		}
+
+		for _, l := range repoLabels {
+			ids[l.Name] = l.ID
+		}
+
+		if len(repoLabels) < giteaPageSize {
+			break
+		}
+	}
+
+	labelIDs := []int64{}
+	for _, name := range labels {
+		id, ok := ids[name]
+		if !ok {
+			return fmt.Errorf("label %s does not exist in repository %s/%s", name, owner, repo)
+		}
+		labelIDs = append(labelIDs, id)
+	}
+
+	path := fmt.Sprintf("%s/issues/%d/labels", p.repoPath(owner, repo), number)
+	if _, err := p.api.do(ctx, http.MethodPut, path, nil, map[string]interface{}{"labels": labelIDs}, nil); err != nil {
+		return fmt.Errorf("failed to update labels: %v", err)
+	}
+	return nil
+}
+
+func (p *giteaProvider) Fork(ctx context.Context, owner, repo, forkOwner string) (string, string, error) {
+	var user struct {
+		Login string `json:"login"`
+	}
+	if _, err := p.api.do(ctx, http.MethodGet, "/user", nil, nil, &user); err != nil {
+		return "", "", fmt.Errorf("failed to get the authenticated user: %v", err)
+	}
+	if forkOwner == "" {
+		forkOwner = user.Login
+	}
+
+	// Reuse the fork of an earlier run
+	var existing giteaRepository
+	resp, err := p.api.do(ctx, http.MethodGet, p.repoPath(forkOwner, repo), nil, nil, &existing)
+	if err == nil {
+		if existing.Parent == nil || existing.Parent.FullName != owner+"/"+repo {
+			return "", "", fmt.Errorf("%s/%s exists and is not a fork of %s/%s", forkOwner, repo, owner, repo)
+		}
+		return forkOwner, repo, nil
+	}
+	if resp == nil || resp.StatusCode != http.StatusNotFound {
+		return "", "", fmt.Errorf("failed to get repository: %v", err)
+	}
+
+	body := map[string]interface{}{}
+	if forkOwner != user.Login {
+		body["organization"] = forkOwner
+	}
+	var fork giteaRepository
+	if _, err := p.api.do(ctx, http.MethodPost, p.repoPath(owner, repo)+"/forks", nil, body, &fork); err != nil {
+		return "", "", fmt.Errorf("failed to fork repository: %v", err)
+	}
+	return forkOwner, fork.Name, nil
+}
+
+func (p *giteaProvider) DeleteFork(ctx context.Context, owner, repo string) error {
+	var r giteaRepository
+	if _, err := p.api.do(ctx, http.MethodGet, p.repoPath(owner, repo), nil, nil, &r); err != nil {
+		return fmt.Errorf("failed to get repository: %v", err)
+	}
+	if !r.Fork {
+		return fmt.Errorf("refusing to delete %s/%s, it is not a fork", owner, repo)
+	}
+
+	if _, err := p.api.do(ctx, http.MethodDelete, p.repoPath(owner, repo), nil, nil, nil); err != nil {
+		return fmt.Errorf("failed to delete fork: %v", err)
+	}
+	return nil
+}
+
+func (p *giteaProvider) CommitVerification(ctx context.Context, owner, repo, sha string) (*CommitVerification, error) {
+	var commit struct {
+		Commit struct {
+			Verification *struct {
+				Verified bool   `json:"verified"`
+				Reason   string `json:"reason"`
+			} `json:"verification"`
+		} `json:"commit"`
+	}
+	path := fmt.Sprintf("%s/git/commits/%s", p.repoPath(owner, repo), sha)
+	if _, err := p.api.do(ctx, http.MethodGet, path, nil, nil, &commit); err != nil {
+		return nil, fmt.Errorf("failed to get commit: %v", err)
+	}
+	if commit.Commit.Verification == nil {
+		return &CommitVerification{Reason: "unsigned"}, nil
+	}
+	return &CommitVerification{
+		Verified: commit.Commit.Verification.Verified,
+		Reason:   commit.Commit.Verification.Reason,
+	}, nil
+}
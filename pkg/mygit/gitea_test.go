@@ -0,0 +1,182 @@
+package mygit
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/nsxbet/proliferate/pkg/types"
+)
+
+const giteaPulls = "/api/v1/repos/org/repo/pulls"
+
+func newFakeGitea(t *testing.T) (*giteaProvider, *fakeAPI) {
+	api := newFakeAPI(t, "Authorization", "token token")
+	return newGiteaProvider(types.ProviderConfig{Host: "git.example.com", BaseURL: api.URL, Token: "token"}), api
+}
+
+// giteaPaged serves items the way Gitea paginates, by page and limit
+func giteaPaged(t *testing.T, items interface{}) func(w http.ResponseWriter, r *http.Request) interface{} {
+	return func(w http.ResponseWriter, r *http.Request) interface{} {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		if page < 1 || limit < 1 {
+			t.Errorf("listed %s without pagination", r.URL.Path)
+			page, limit = 1, 1<<30
+		}
+		return slice(items, (page-1)*limit, limit)
+	}
+}
+
+// manyLabels returns n filler labels followed by the given ones
+func manyLabels(n int, names ...string) []giteaLabel {
+	var labels []giteaLabel
+	for i := 0; i < n; i++ {
+		labels = append(labels, giteaLabel{ID: int64(i + 1), Name: fmt.Sprintf("label-%d", i)})
+	}
+	for i, name := range names {
+		labels = append(labels, giteaLabel{ID: int64(1000 + i), Name: name})
+	}
+	return labels
+}
+
+func TestGiteaCreatePRMarksDraftWithWIP(t *testing.T) {
+	p, api := newFakeGitea(t)
+	api.handle("GET "+giteaPulls, giteaPaged(t, []giteaPullRequest{}))
+	api.handle("POST "+giteaPulls, func(w http.ResponseWriter, r *http.Request) interface{} {
+		return giteaPullRequest{Number: 9, HTMLURL: "https://git.example.com/org/repo/pulls/9", State: "open", Title: "WIP: Bump deps"}
+	})
+	// The label only shows up on the third page
+	api.handle("GET /api/v1/repos/org/repo/labels", giteaPaged(t, manyLabels(2*giteaPageSize, "deps")))
+	api.reply("PUT /api/v1/repos/org/repo/issues/9/labels", []giteaLabel{})
+
+	pr, err := p.CreatePR(context.Background(), "org", "repo", PROptions{
+		Title:  "Bump deps",
+		Body:   "body",
+		Branch: "bump",
+		Base:   "main",
+		Labels: []string{"deps"},
+		Draft:  true,
+	})
+	if err != nil {
+		t.Fatalf("CreatePR: %v", err)
+	}
+	if pr.Number != 9 || pr.State != "open" || !pr.Draft {
+		t.Errorf("got PR %d in state %s draft=%v, want 9 open draft", pr.Number, pr.State, pr.Draft)
+	}
+
+	// Gitea has no draft flag, the WIP: prefix makes the PR a draft
+	want := map[string]interface{}{
+		"head":      "bump",
+		"base":      "main",
+		"title":     "WIP: Bump deps",
+		"body":      "body",
+		"assignees": []interface{}{},
+	}
+	if got := api.received("POST " + giteaPulls); !reflect.DeepEqual(got, want) {
+		t.Errorf("created PR with %v, want %v", got, want)
+	}
+	if got := api.received("PUT /api/v1/repos/org/repo/issues/9/labels")["labels"]; !reflect.DeepEqual(got, []interface{}{1000.0}) {
+		t.Errorf("set labels %v, want [1000]", got)
+	}
+}
+
+func TestGiteaUpdateKeepsWIPPrefix(t *testing.T) {
+	p, api := newFakeGitea(t)
+	// The open PR is on the second page, behind PRs of other branches
+	var pulls []giteaPullRequest
+	for i := 0; i < giteaPageSize; i++ {
+		pr := giteaPullRequest{Number: 100 + i, State: "open"}
+		pr.Head.Ref, pr.Base.Ref = fmt.Sprintf("other-%d", i), "main"
+		pulls = append(pulls, pr)
+	}
+	existing := giteaPullRequest{Number: 4, State: "open", Title: "WIP: Old title"}
+	existing.Head.Ref, existing.Base.Ref = "bump", "main"
+	pulls = append(pulls, existing)
+	api.handle("GET "+giteaPulls, giteaPaged(t, pulls))
+	api.reply("PATCH "+giteaPulls+"/4", giteaPullRequest{Number: 4, State: "open", Title: "WIP: Bump deps"})
+	api.handle("GET /api/v1/repos/org/repo/labels", giteaPaged(t, manyLabels(0, "deps")))
+	api.reply("PUT /api/v1/repos/org/repo/issues/4/labels", []giteaLabel{})
+
+	pr, err := p.CreatePR(context.Background(), "org", "repo", PROptions{
+		Title:     "Bump deps",
+		Branch:    "bump",
+		Base:      "main",
+		Labels:    []string{"deps"},
+		Assignees: []string{"alice"},
+	})
+	if err != nil {
+		t.Fatalf("CreatePR: %v", err)
+	}
+	if pr.Number != 4 {
+		t.Errorf("got PR %d, want 4", pr.Number)
+	}
+	if api.received("POST "+giteaPulls) != nil {
+		t.Errorf("created a second PR")
+	}
+	updated := api.received("PATCH " + giteaPulls + "/4")
+	if got := updated["title"]; got != "WIP: Bump deps" {
+		t.Errorf("updated title to %q", got)
+	}
+	if got := updated["assignees"]; !reflect.DeepEqual(got, []interface{}{"alice"}) {
+		t.Errorf("updated assignees to %v", got)
+	}
+}
+
+func TestGiteaUnknownLabel(t *testing.T) {
+	p, api := newFakeGitea(t)
+	api.handle("GET "+giteaPulls, giteaPaged(t, []giteaPullRequest{}))
+	api.reply("POST "+giteaPulls, giteaPullRequest{Number: 9, State: "open"})
+	api.handle("GET /api/v1/repos/org/repo/labels", giteaPaged(t, manyLabels(3)))
+
+	_, err := p.CreatePR(context.Background(), "org", "repo", PROptions{Branch: "bump", Base: "main", Labels: []string{"missing"}})
+	if err == nil {
+		t.Fatal("CreatePR succeeded with a label missing from the repository")
+	}
+}
+
+func TestGiteaMergedPRIsClosedWithMergedFlag(t *testing.T) {
+	p, api := newFakeGitea(t)
+	api.reply("GET "+giteaPulls+"/1", giteaPullRequest{Number: 1, State: "open"})
+	api.reply("GET "+giteaPulls+"/2", giteaPullRequest{Number: 2, State: "closed"})
+	api.reply("GET "+giteaPulls+"/3", giteaPullRequest{Number: 3, State: "closed", Merged: true})
+
+	for number, want := range map[int]string{1: "open", 2: "closed", 3: "merged"} {
+		got, err := p.GetPRStatus(context.Background(), "org", "repo", number)
+		if err != nil {
+			t.Fatalf("GetPRStatus: %v", err)
+		}
+		if got != want {
+			t.Errorf("PR %d is %s, want %s", number, got, want)
+		}
+	}
+
+	if _, err := p.GetPRStatus(context.Background(), "org", "repo", 42); err == nil {
+		t.Error("expected an error for a missing PR")
+	}
+}
+
+func TestGiteaSkipsForksAndArchivedRepos(t *testing.T) {
+	p, api := newFakeGitea(t)
+	var repos []giteaRepository
+	for i := 0; i < giteaPageSize; i++ {
+		repos = append(repos, giteaRepository{Name: fmt.Sprintf("lib-%d", i)})
+	}
+	repos = append(repos,
+		giteaRepository{Name: "svc-a"},
+		giteaRepository{Name: "svc-fork", Fork: true},
+		giteaRepository{Name: "svc-old", Archived: true},
+	)
+	api.handle("GET /api/v1/orgs/org/repos", giteaPaged(t, repos))
+
+	got, err := p.FilterRepositoriesByOrg(context.Background(), "org", "^svc-")
+	if err != nil {
+		t.Fatalf("FilterRepositoriesByOrg: %v", err)
+	}
+	if want := []string{"git.example.com/org/svc-a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
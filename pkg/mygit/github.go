@@ -3,22 +3,134 @@ package mygit
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"regexp"
 	"strings"
 
 	"github.com/google/go-github/github"
+	"golang.org/x/oauth2"
+
+	"github.com/nsxbet/proliferate/pkg/types"
 )
 
-func (g *Git) ParseRepoString(repoStr string) (owner string, repo string, err error) {
-	parts := strings.Split(repoStr, "/")
-	if len(parts) != 3 {
-		return "", "", fmt.Errorf("invalid repo format, expected 'github.com/owner/repo'")
+// githubPullRequest adds the draft flag that go-github v17 does not know about
+type githubPullRequest struct {
+	github.PullRequest
+	Draft bool `json:"draft"`
+}
+
+type githubProvider struct {
+	host  string
+	token string
+	gh    *github.Client
+	// app replaces the static token and client when GitHub App auth is configured
+	app *githubApp
+}
+
+// newGitHubProvider talks to api.github.com for github.com and to the
+// /api/v3 endpoints of GitHub Enterprise Server for any other host
+func newGitHubProvider(cfg types.ProviderConfig) (*githubProvider, error) {
+	newClient := func(httpClient *http.Client) (*github.Client, error) {
+		return github.NewClient(httpClient), nil
+	}
+	if cfg.Host != defaultHost || cfg.BaseURL != "" {
+		baseURL := cfg.BaseURL
+		if baseURL == "" {
+			baseURL = fmt.Sprintf("https://%s/api/v3/", cfg.Host)
+		}
+		uploadURL := cfg.UploadURL
+		if uploadURL == "" {
+			uploadURL = fmt.Sprintf("https://%s/api/uploads/", cfg.Host)
+		}
+
+		newClient = func(httpClient *http.Client) (*github.Client, error) {
+			gh, err := github.NewEnterpriseClient(baseURL, uploadURL, httpClient)
+			if err != nil {
+				return nil, fmt.Errorf("invalid GitHub Enterprise URLs for %s: %v", cfg.Host, err)
+			}
+			return gh, nil
+		}
+	}
+
+	p := &githubProvider{
+		host:  cfg.Host,
+		token: cfg.Token,
+	}
+
+	if cfg.App != nil {
+		app, err := newGitHubApp(*cfg.App, newClient)
+		if err != nil {
+			return nil, err
+		}
+		p.app = app
+		return p, nil
+	}
+
+	var httpClient *http.Client
+	if cfg.Token != "" {
+		ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: cfg.Token})
+		httpClient = oauth2.NewClient(context.Background(), ts)
+	}
+
+	gh, err := newClient(httpClient)
+	if err != nil {
+		return nil, err
 	}
-	return parts[1], parts[2], nil
+	p.gh = gh
+	return p, nil
 }
 
-func (g *Git) GetPRStatus(ctx context.Context, owner, repo string, number int) (string, error) {
-	pr, _, err := g.gh.PullRequests.Get(ctx, owner, repo, number)
+// client returns the API client authorized for the repositories of owner
+func (p *githubProvider) client(ctx context.Context, owner string) (*github.Client, error) {
+	if p.app != nil {
+		return p.app.client(ctx, owner)
+	}
+	return p.gh, nil
+}
+
+func (p *githubProvider) CloneURL(owner, repo string) string {
+	return fmt.Sprintf("https://%s/%s/%s.git", p.host, owner, repo)
+}
+
+func (p *githubProvider) Credentials(ctx context.Context, owner string) (string, string, error) {
+	if p.app != nil {
+		token, err := p.app.token(ctx, owner)
+		if err != nil {
+			return "", "", err
+		}
+		return "x-access-token", token, nil
+	}
+	return "oauth2", p.token, nil
+}
+
+func (p *githubProvider) DescribePR(ctx context.Context, owner, repo string, number int) (*PRMetadata, error) {
+	gh, err := p.client(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	pr, _, err := gh.PullRequests.Get(ctx, owner, repo, number)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get PR: %v", err)
+	}
+
+	metadata := &PRMetadata{Title: pr.GetTitle(), Body: pr.GetBody()}
+	for _, label := range pr.Labels {
+		metadata.Labels = append(metadata.Labels, label.GetName())
+	}
+	for _, assignee := range pr.Assignees {
+		metadata.Assignees = append(metadata.Assignees, assignee.GetLogin())
+	}
+	return metadata, nil
+}
+
+func (p *githubProvider) GetPRStatus(ctx context.Context, owner, repo string, number int) (string, error) {
+	gh, err := p.client(ctx, owner)
+	if err != nil {
+		return "", err
+	}
+
+	pr, _, err := gh.PullRequests.Get(ctx, owner, repo, number)
 	if err != nil {
 		return "", fmt.Errorf("failed to get PR status: %v", err)
 	}
@@ -29,8 +141,133 @@ func (g *Git) GetPRStatus(ctx context.Context, owner, repo string, number int) (
 	return pr.GetState(), nil
 }
 
+func (p *githubProvider) ClosePR(ctx context.Context, owner, repo string, number int, comment string) error {
+	gh, err := p.client(ctx, owner)
+	if err != nil {
+		return err
+	}
+
+	if comment != "" {
+		_, _, err = gh.Issues.CreateComment(ctx, owner, repo, number, &github.IssueComment{Body: github.String(comment)})
+		if err != nil {
+			return fmt.Errorf("failed to comment on PR: %v", err)
+		}
+	}
+
+	_, _, err = gh.PullRequests.Edit(ctx, owner, repo, number, &github.PullRequest{State: github.String("closed")})
+	if err != nil {
+		return fmt.Errorf("failed to close PR: %v", err)
+	}
+	return nil
+}
+
+func (p *githubProvider) MergeState(ctx context.Context, owner, repo string, number int) (*MergeState, error) {
+	gh, err := p.client(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	pr, _, err := gh.PullRequests.Get(ctx, owner, repo, number)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get PR: %v", err)
+	}
+	state := &MergeState{State: pr.GetState(), HeadSHA: pr.GetHead().GetSHA()}
+	if pr.GetMerged() {
+		state.State = "merged"
+	}
+
+	// CI reports either through commit statuses or through check runs
+	var checks []string
+	combined, _, err := gh.Repositories.GetCombinedStatus(ctx, owner, repo, state.HeadSHA, &github.ListOptions{PerPage: 100})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get commit status: %v", err)
+	}
+	for _, s := range combined.Statuses {
+		switch s.GetState() {
+		case "success":
+			checks = append(checks, ChecksSuccess)
+		case "pending":
+			checks = append(checks, ChecksPending)
+		default:
+			checks = append(checks, ChecksFailure)
+		}
+	}
+
+	runs, _, err := gh.Checks.ListCheckRunsForRef(ctx, owner, repo, state.HeadSHA, &github.ListCheckRunsOptions{
+		ListOptions: github.ListOptions{PerPage: 100},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list check runs: %v", err)
+	}
+	for _, run := range runs.CheckRuns {
+		switch {
+		case run.GetStatus() != "completed":
+			checks = append(checks, ChecksPending)
+		case run.GetConclusion() == "success", run.GetConclusion() == "neutral", run.GetConclusion() == "skipped":
+			checks = append(checks, ChecksSuccess)
+		default:
+			checks = append(checks, ChecksFailure)
+		}
+	}
+	state.Checks = combineChecks(checks)
+
+	reviews, _, err := gh.PullRequests.ListReviews(ctx, owner, repo, number, &github.ListOptions{PerPage: 100})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reviews: %v", err)
+	}
+	verdicts := make(map[string]string)
+	for _, review := range reviews {
+		login := review.GetUser().GetLogin()
+		switch review.GetState() {
+		case "APPROVED":
+			verdicts[login] = reviewApproved
+		case "CHANGES_REQUESTED":
+			verdicts[login] = reviewChangesRequested
+		case "DISMISSED":
+			delete(verdicts, login)
+		}
+	}
+	state.Approvals, state.ChangesRequested = tallyReviews(verdicts)
+
+	return state, nil
+}
+
+func (p *githubProvider) MergePR(ctx context.Context, owner, repo string, number int, method, headSHA string) (string, error) {
+	gh, err := p.client(ctx, owner)
+	if err != nil {
+		return "", err
+	}
+
+	result, _, err := gh.PullRequests.Merge(ctx, owner, repo, number, "", &github.PullRequestOptions{
+		MergeMethod: method,
+		SHA:         headSHA,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to merge PR: %v", err)
+	}
+	return result.GetSHA(), nil
+}
+
+func (p *githubProvider) DefaultBranch(ctx context.Context, owner, repo string) (string, error) {
+	gh, err := p.client(ctx, owner)
+	if err != nil {
+		return "", err
+	}
+
+	r, _, err := gh.Repositories.Get(ctx, owner, repo)
+	if err != nil {
+		return "", fmt.Errorf("failed to get repository: %v", err)
+	}
+	return r.GetDefaultBranch(), nil
+}
+
 // FilterRepositoriesByOrg fetches repositories from a GitHub organization matching a regex pattern
-func (g *Git) FilterRepositoriesByOrg(ctx context.Context, org, pattern string) ([]string, error) {
+func (p *githubProvider) FilterRepositoriesByOrg(ctx context.Context, org, pattern string) ([]string, error) {
+	gh, err := p.client(ctx, org)
+	if err != nil {
+		return nil, err
+	}
+
 	var allRepos []string
 
 	// List repositories for the organization
@@ -39,7 +276,7 @@ func (g *Git) FilterRepositoriesByOrg(ctx context.Context, org, pattern string)
 	}
 
 	for {
-		repos, resp, err := g.gh.Repositories.ListByOrg(ctx, org, opt)
+		repos, resp, err := gh.Repositories.ListByOrg(ctx, org, opt)
 		if err != nil {
 			return nil, fmt.Errorf("failed to list repositories: %v", err)
 		}
@@ -58,7 +295,7 @@ func (g *Git) FilterRepositoriesByOrg(ctx context.Context, org, pattern string)
 			}
 
 			if matched {
-				repoURL := fmt.Sprintf("github.com/%s/%s", org, repoName)
+				repoURL := fmt.Sprintf("%s/%s/%s", p.host, org, repoName)
 				allRepos = append(allRepos, repoURL)
 			}
 		}
@@ -77,3 +314,230 @@ func (g *Git) FilterRepositoriesByOrg(ctx context.Context, org, pattern string)
 
 	return allRepos, nil
 }
+
+func (p *githubProvider) CreatePR(ctx context.Context, owner, repo string, opts PROptions) (*PullRequest, error) {
+	gh, err := p.client(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	headOwner, head := owner, opts.Branch
+	if opts.HeadOwner != "" {
+		headOwner, head = opts.HeadOwner, opts.HeadOwner+":"+opts.Branch
+	}
+
+	existingPRs, _, err := gh.PullRequests.List(ctx, owner, repo, &github.PullRequestListOptions{
+		Head: fmt.Sprintf("%s:%s", headOwner, opts.Branch),
+		Base: opts.Base,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list PRs: %v", err)
+	}
+
+	var matchingPR *github.PullRequest
+	for _, existingPR := range existingPRs {
+		if existingPR.GetHead().GetRef() == opts.Branch {
+			matchingPR = existingPR
+			break
+		}
+	}
+
+	// Raw requests, as go-github v17 cannot open drafts nor report the draft state
+	pr := &githubPullRequest{}
+	if matchingPR != nil {
+		path := fmt.Sprintf("repos/%s/%s/pulls/%d", owner, repo, matchingPR.GetNumber())
+		req, err := gh.NewRequest("PATCH", path, map[string]interface{}{
+			"title": opts.Title,
+			"body":  opts.Body,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := gh.Do(ctx, req, pr); err != nil {
+			return nil, fmt.Errorf("failed to update PR: %v", err)
+		}
+	} else {
+		req, err := gh.NewRequest("POST", fmt.Sprintf("repos/%s/%s/pulls", owner, repo), map[string]interface{}{
+			"title":                 opts.Title,
+			"head":                  head,
+			"base":                  opts.Base,
+			"body":                  opts.Body,
+			"maintainer_can_modify": true,
+			"draft":                 opts.Draft,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := gh.Do(ctx, req, pr); err != nil {
+			return nil, fmt.Errorf("failed to create PR: %v", err)
+		}
+	}
+
+	_, _, err = gh.Issues.ReplaceLabelsForIssue(ctx, owner, repo, pr.GetNumber(), opts.Labels)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update labels: %v", err)
+	}
+
+	if len(pr.Assignees) > 0 {
+		var currentAssignees []string
+		for _, a := range pr.Assignees {
+			currentAssignees = append(currentAssignees, a.GetLogin())
+		}
+		_, _, err = gh.Issues.RemoveAssignees(ctx, owner, repo, pr.GetNumber(), currentAssignees)
+		if err != nil {
+			return nil, fmt.Errorf("failed to remove existing assignees: %v", err)
+		}
+	}
+
+	if len(opts.Assignees) > 0 {
+		_, _, err = gh.Issues.AddAssignees(ctx, owner, repo, pr.GetNumber(), opts.Assignees)
+		if err != nil {
+			return nil, fmt.Errorf("failed to add assignees: %v", err)
+		}
+	}
+
+	return &PullRequest{
+		Number:   pr.GetNumber(),
+		URL:      pr.GetHTMLURL(),
+		State:    pr.GetState(),
+		Draft:    pr.Draft,
+		Warnings: requestGitHubReviewers(ctx, gh, owner, repo, pr.GetNumber(), opts.Reviewers, opts.TeamReviewers),
+	}, nil
+}
+
+// requestGitHubReviewers requests reviews, returning a warning for every
+// reviewer that cannot be requested. GitHub rejects the whole batch when one
+// reviewer is invalid, so a failed batch is retried one reviewer at a time.
+func requestGitHubReviewers(ctx context.Context, gh *github.Client, owner, repo string, number int, reviewers, teams []string) []string {
+	if len(reviewers) == 0 && len(teams) == 0 {
+		return nil
+	}
+
+	request := github.ReviewersRequest{Reviewers: reviewers, TeamReviewers: teams}
+	if _, _, err := gh.PullRequests.RequestReviewers(ctx, owner, repo, number, request); err == nil {
+		return nil
+	}
+
+	var warnings []string
+	for _, reviewer := range reviewers {
+		request := github.ReviewersRequest{Reviewers: []string{reviewer}}
+		if _, _, err := gh.PullRequests.RequestReviewers(ctx, owner, repo, number, request); err != nil {
+			warnings = append(warnings, fmt.Sprintf("could not request review from %s: %v", reviewer, err))
+		}
+	}
+	for _, team := range teams {
+		request := github.ReviewersRequest{TeamReviewers: []string{team}}
+		if _, _, err := gh.PullRequests.RequestReviewers(ctx, owner, repo, number, request); err != nil {
+			warnings = append(warnings, fmt.Sprintf("could not request review from team %s: %v", team, err))
+		}
+	}
+	return warnings
+}
+
+func (p *githubProvider) Fork(ctx context.Context, owner, repo, forkOwner string) (string, string, error) {
+	gh, err := p.client(ctx, owner)
+	if err != nil {
+		return "", "", err
+	}
+
+	var opts github.RepositoryCreateForkOptions
+	if forkOwner == "" {
+		user, _, err := gh.Users.Get(ctx, "")
+		if err != nil {
+			return "", "", fmt.Errorf("failed to get the authenticated user, set fork.owner: %v", err)
+		}
+		forkOwner = user.GetLogin()
+	} else {
+		account, _, err := gh.Users.Get(ctx, forkOwner)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to get fork owner %s: %v", forkOwner, err)
+		}
+		if account.GetType() == "Organization" {
+			opts.Organization = forkOwner
+		} else if err := checkForkUser(ctx, gh, forkOwner); err != nil {
+			return "", "", err
+		}
+	}
+
+	// Reuse the fork of an earlier run
+	existing, resp, err := gh.Repositories.Get(ctx, forkOwner, repo)
+	if err == nil {
+		if existing.GetParent().GetFullName() != owner+"/"+repo {
+			return "", "", fmt.Errorf("%s/%s exists and is not a fork of %s/%s", forkOwner, repo, owner, repo)
+		}
+		return forkOwner, repo, nil
+	}
+	if resp == nil || resp.StatusCode != http.StatusNotFound {
+		return "", "", fmt.Errorf("failed to get repository: %v", err)
+	}
+
+	fork, _, err := gh.Repositories.CreateFork(ctx, owner, repo, &opts)
+	if _, accepted := err.(*github.AcceptedError); err != nil && !accepted {
+		return "", "", fmt.Errorf("failed to fork repository: %v", err)
+	}
+	forkRepo := repo
+	if fork != nil && fork.GetName() != "" {
+		forkRepo = fork.GetName()
+	}
+
+	err = waitForFork(ctx, forkOwner+"/"+forkRepo, func() bool {
+		_, _, err := gh.Repositories.Get(ctx, forkOwner, forkRepo)
+		return err == nil
+	})
+	if err != nil {
+		return "", "", err
+	}
+	return forkOwner, forkRepo, nil
+}
+
+// checkForkUser fails unless login is the authenticated user, since GitHub
+// forks into organizations or the authenticated user only
+func checkForkUser(ctx context.Context, gh *github.Client, login string) error {
+	user, _, err := gh.Users.Get(ctx, "")
+	if err != nil {
+		return fmt.Errorf("failed to get the authenticated user: %v", err)
+	}
+	if !strings.EqualFold(user.GetLogin(), login) {
+		return fmt.Errorf("cannot fork into user %s, GitHub only forks into organizations or the authenticated user %s", login, user.GetLogin())
+	}
+	return nil
+}
+
+func (p *githubProvider) DeleteFork(ctx context.Context, owner, repo string) error {
+	gh, err := p.client(ctx, owner)
+	if err != nil {
+		return err
+	}
+
+	r, _, err := gh.Repositories.Get(ctx, owner, repo)
+	if err != nil {
+		return fmt.Errorf("failed to get repository: %v", err)
+	}
+	if !r.GetFork() {
+		return fmt.Errorf("refusing to delete %s/%s, it is not a fork", owner, repo)
+	}
+
+	if _, err := gh.Repositories.Delete(ctx, owner, repo); err != nil {
+		return fmt.Errorf("failed to delete fork: %v", err)
+	}
+	return nil
+}
+
+func (p *githubProvider) CommitVerification(ctx context.Context, owner, repo, sha string) (*CommitVerification, error) {
+	gh, err := p.client(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	commit, _, err := gh.Git.GetCommit(ctx, owner, repo, sha)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get commit: %v", err)
+	}
+	if commit.Verification == nil {
+		return &CommitVerification{Reason: "unsigned"}, nil
+	}
+	return &CommitVerification{
+		Verified: commit.Verification.GetVerified(),
+		Reason:   commit.Verification.GetReason(),
+	}, nil
+}
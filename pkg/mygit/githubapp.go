@@ -0,0 +1,222 @@
+package mygit
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/github"
+	"golang.org/x/oauth2"
+
+	"github.com/nsxbet/proliferate/pkg/redact"
+	"github.com/nsxbet/proliferate/pkg/types"
+)
+
+// githubApp authenticates as a GitHub App, minting installation tokens on
+// demand. Without a configured installation ID, the installation is looked
+// up for each repository owner.
+type githubApp struct {
+	appID          int64
+	key            *rsa.PrivateKey
+	installationID int64
+	newClient      func(*http.Client) (*github.Client, error)
+	appClient      *github.Client
+
+	mu            sync.Mutex
+	ownerInstalls map[string]int64
+	installations map[int64]*githubInstallation
+}
+
+type githubInstallation struct {
+	client *github.Client
+	tokens oauth2.TokenSource
+}
+
+func newGitHubApp(cfg types.GitHubAppConfig, newClient func(*http.Client) (*github.Client, error)) (*githubApp, error) {
+	if cfg.AppID == 0 || cfg.PrivateKeyPath == "" {
+		return nil, fmt.Errorf("github app requires both app-id and private-key-path")
+	}
+
+	keyData, err := os.ReadFile(cfg.PrivateKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read github app private key: %v", err)
+	}
+	key, err := parseRSAPrivateKey(keyData)
+	if err != nil {
+		return nil, err
+	}
+
+	app := &githubApp{
+		appID:          cfg.AppID,
+		key:            key,
+		installationID: cfg.InstallationID,
+		newClient:      newClient,
+		ownerInstalls:  make(map[string]int64),
+		installations:  make(map[int64]*githubInstallation),
+	}
+
+	app.appClient, err = newClient(&http.Client{Transport: &appTransport{app: app}})
+	if err != nil {
+		return nil, err
+	}
+	return app, nil
+}
+
+func parseRSAPrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("github app private key is not PEM encoded")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse github app private key: %v", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("github app private key is not an RSA key")
+	}
+	return key, nil
+}
+
+// jwt returns a short lived RS256 token identifying the app itself
+func (a *githubApp) jwt() (string, error) {
+	now := time.Now()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]interface{}{
+		// Backdated to allow for clock drift, as recommended by GitHub
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": strconv.FormatInt(a.appID, 10),
+	})
+
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, a.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign github app token: %v", err)
+	}
+	return unsigned + "." + enc.EncodeToString(signature), nil
+}
+
+// appTransport authenticates requests made as the app, not as an installation
+type appTransport struct {
+	app *githubApp
+}
+
+func (t *appTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.app.jwt()
+	if err != nil {
+		return nil, err
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+token)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// installationTokenSource mints installation tokens, refreshed a minute before they expire
+type installationTokenSource struct {
+	app *githubApp
+	id  int64
+}
+
+func (s *installationTokenSource) Token() (*oauth2.Token, error) {
+	// The vendored go-github still targets the deprecated /installations endpoint
+	u := fmt.Sprintf("app/installations/%d/access_tokens", s.id)
+	req, err := s.app.appClient.NewRequest(http.MethodPost, u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	token := new(github.InstallationToken)
+	if _, err := s.app.appClient.Do(context.Background(), req, token); err != nil {
+		return nil, fmt.Errorf("failed to create installation token: %v", err)
+	}
+	redact.Register(token.GetToken())
+	return &oauth2.Token{
+		AccessToken: token.GetToken(),
+		Expiry:      token.GetExpiresAt().Add(-time.Minute),
+	}, nil
+}
+
+func (a *githubApp) installation(ctx context.Context, owner string) (*githubInstallation, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	id := a.installationID
+	if id == 0 {
+		var err error
+		if id, err = a.findInstallation(ctx, owner); err != nil {
+			return nil, err
+		}
+	}
+
+	if inst, ok := a.installations[id]; ok {
+		return inst, nil
+	}
+
+	tokens := oauth2.ReuseTokenSource(nil, &installationTokenSource{app: a, id: id})
+	client, err := a.newClient(oauth2.NewClient(context.Background(), tokens))
+	if err != nil {
+		return nil, err
+	}
+
+	inst := &githubInstallation{client: client, tokens: tokens}
+	a.installations[id] = inst
+	return inst, nil
+}
+
+// findInstallation looks up the app installation of an organization or user, caching the result
+func (a *githubApp) findInstallation(ctx context.Context, owner string) (int64, error) {
+	if id, ok := a.ownerInstalls[owner]; ok {
+		return id, nil
+	}
+
+	inst, _, err := a.appClient.Apps.FindOrganizationInstallation(ctx, owner)
+	if err != nil {
+		var userErr error
+		inst, _, userErr = a.appClient.Apps.FindUserInstallation(ctx, owner)
+		if userErr != nil {
+			return 0, fmt.Errorf("github app %d is not installed for %s: %v", a.appID, owner, err)
+		}
+	}
+
+	a.ownerInstalls[owner] = inst.GetID()
+	return inst.GetID(), nil
+}
+
+func (a *githubApp) client(ctx context.Context, owner string) (*github.Client, error) {
+	inst, err := a.installation(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	return inst.client, nil
+}
+
+func (a *githubApp) token(ctx context.Context, owner string) (string, error) {
+	inst, err := a.installation(ctx, owner)
+	if err != nil {
+		return "", err
+	}
+	token, err := inst.tokens.Token()
+	if err != nil {
+		return "", err
+	}
+	return token.AccessToken, nil
+}
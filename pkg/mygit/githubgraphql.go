@@ -0,0 +1,126 @@
+package mygit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-github/github"
+)
+
+// graphql runs a query against the GraphQL API next to the REST API of gh
+func graphql(ctx context.Context, gh *github.Client, query string, variables map[string]interface{}, out interface{}) error {
+	// api.github.com/graphql on github.com, <host>/api/graphql on GitHub Enterprise Server
+	endpoint := "graphql"
+	if strings.HasSuffix(gh.BaseURL.Path, "/api/v3/") {
+		endpoint = strings.TrimSuffix(gh.BaseURL.Path, "v3/") + "graphql"
+	}
+
+	req, err := gh.NewRequest("POST", endpoint, map[string]interface{}{
+		"query":     query,
+		"variables": variables,
+	})
+	if err != nil {
+		return err
+	}
+
+	var resp struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if _, err := gh.Do(ctx, req, &resp); err != nil {
+		return err
+	}
+	if len(resp.Errors) > 0 {
+		return fmt.Errorf("%s", resp.Errors[0].Message)
+	}
+	return json.Unmarshal(resp.Data, out)
+}
+
+const enableAutoMergeMutation = `mutation($id: ID!, $method: PullRequestMergeMethod, $headline: String) {
+  enablePullRequestAutoMerge(input: {pullRequestId: $id, mergeMethod: $method, commitHeadline: $headline}) {
+    pullRequest { number }
+  }
+}`
+
+const markReadyMutation = `mutation($id: ID!) {
+  markPullRequestReadyForReview(input: {pullRequestId: $id}) {
+    pullRequest { number }
+  }
+}`
+
+const autoMergeQuery = `query($owner: String!, $repo: String!, $number: Int!) {
+  repository(owner: $owner, name: $repo) {
+    pullRequest(number: $number) { autoMergeRequest { enabledAt } }
+  }
+}`
+
+func (p *githubProvider) EnableAutoMerge(ctx context.Context, owner, repo string, number int, opts AutoMergeOptions) error {
+	gh, err := p.client(ctx, owner)
+	if err != nil {
+		return err
+	}
+
+	pr, _, err := gh.PullRequests.Get(ctx, owner, repo, number)
+	if err != nil {
+		return fmt.Errorf("failed to get PR: %v", err)
+	}
+
+	variables := map[string]interface{}{"id": pr.GetNodeID()}
+	if opts.Method != "" {
+		variables["method"] = strings.ToUpper(opts.Method)
+	}
+	if opts.CommitHeadline != "" {
+		variables["headline"] = opts.CommitHeadline
+	}
+
+	var out json.RawMessage
+	if err := graphql(ctx, gh, enableAutoMergeMutation, variables, &out); err != nil {
+		return fmt.Errorf("failed to enable auto-merge: %v", err)
+	}
+	return nil
+}
+
+// MarkReady uses GraphQL, the REST API cannot take a pull request out of draft
+func (p *githubProvider) MarkReady(ctx context.Context, owner, repo string, number int) error {
+	gh, err := p.client(ctx, owner)
+	if err != nil {
+		return err
+	}
+
+	pr, _, err := gh.PullRequests.Get(ctx, owner, repo, number)
+	if err != nil {
+		return fmt.Errorf("failed to get PR: %v", err)
+	}
+
+	var out json.RawMessage
+	if err := graphql(ctx, gh, markReadyMutation, map[string]interface{}{"id": pr.GetNodeID()}, &out); err != nil {
+		return fmt.Errorf("failed to mark PR ready for review: %v", err)
+	}
+	return nil
+}
+
+func (p *githubProvider) AutoMergeEnabled(ctx context.Context, owner, repo string, number int) (bool, error) {
+	gh, err := p.client(ctx, owner)
+	if err != nil {
+		return false, err
+	}
+
+	var out struct {
+		Repository struct {
+			PullRequest struct {
+				AutoMergeRequest *struct {
+					EnabledAt string `json:"enabledAt"`
+				} `json:"autoMergeRequest"`
+			} `json:"pullRequest"`
+		} `json:"repository"`
+	}
+	variables := map[string]interface{}{"owner": owner, "repo": repo, "number": number}
+	if err := graphql(ctx, gh, autoMergeQuery, variables, &out); err != nil {
+		return false, fmt.Errorf("failed to get auto-merge state: %v", err)
+	}
+	return out.Repository.PullRequest.AutoMergeRequest != nil, nil
+}
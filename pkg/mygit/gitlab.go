@@ -0,0 +1,368 @@
+package mygit
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/nsxbet/proliferate/pkg/types"
+)
+
+type gitlabProvider struct {
+	host  string
+	token string
+	api   *restClient
+}
+
+type gitlabMergeRequest struct {
+	IID         int          `json:"iid"`
+	WebURL      string       `json:"web_url"`
+	State       string       `json:"state"`
+	Title       string       `json:"title"`
+	Description string       `json:"description"`
+	Draft       bool         `json:"draft"`
+	Labels      []string     `json:"labels"`
+	Assignees   []gitlabUser `json:"assignees"`
+}
+
+type gitlabProject struct {
+	Path              string    `json:"path"`
+	PathWithNamespace string    `json:"path_with_namespace"`
+	Archived          bool      `json:"archived"`
+	ForkedFromProject *struct{} `json:"forked_from_project"`
+}
+
+type gitlabUser struct {
+	ID       int    `json:"id"`
+	Username string `json:"username"`
+}
+
+func newGitLabProvider(cfg types.ProviderConfig) *gitlabProvider {
+	baseURL := cfg.BaseURL
+	if baseURL == "" {
+		baseURL = fmt.Sprintf("https://%s/api/v4", cfg.Host)
+	}
+
+	header := http.Header{}
+	header.Set("PRIVATE-TOKEN", cfg.Token)
+
+	return &gitlabProvider{
+		host:  cfg.Host,
+		token: cfg.Token,
+		api:   newRestClient(baseURL, header),
+	}
+}
+
+// projectPath returns the URL encoded project ID used by the GitLab API
+func (p *gitlabProvider) projectPath(owner, repo string) string {
+	return "/projects/" + url.PathEscape(owner+"/"+repo)
+}
+
+func (p *gitlabProvider) CloneURL(owner, repo string) string {
+	return fmt.Sprintf("https://%s/%s/%s.git", p.host, owner, repo)
+}
+
+func (p *gitlabProvider) Credentials(ctx context.Context, owner string) (string, string, error) {
+	return "oauth2", p.token, nil
+}
+
+func (p *gitlabProvider) DescribePR(ctx context.Context, owner, repo string, number int) (*PRMetadata, error) {
+	var mr gitlabMergeRequest
+	path := fmt.Sprintf("%s/merge_requests/%d", p.projectPath(owner, repo), number)
+	if _, err := p.api.do(ctx, http.MethodGet, path, nil, nil, &mr); err != nil {
+		return nil, fmt.Errorf("failed to get MR: %v", err)
+	}
+
+	metadata := &PRMetadata{Title: mr.Title, Body: mr.Description, Labels: mr.Labels}
+	for _, assignee := range mr.Assignees {
+		metadata.Assignees = append(metadata.Assignees, assignee.Username)
+	}
+	return metadata, nil
+}
+
+func (p *gitlabProvider) GetPRStatus(ctx context.Context, owner, repo string, number int) (string, error) {
+	var mr gitlabMergeRequest
+	path := fmt.Sprintf("%s/merge_requests/%d", p.projectPath(owner, repo), number)
+	if _, err := p.api.do(ctx, http.MethodGet, path, nil, nil, &mr); err != nil {
+		return "", fmt.Errorf("failed to get MR status: %v", err)
+	}
+	return gitlabState(mr.State), nil
+}
+
+func gitlabState(state string) string {
+	switch state {
+	case "opened", "locked":
+		return "open"
+	default:
+		return state
+	}
+}
+
+func (p *gitlabProvider) ClosePR(ctx context.Context, owner, repo string, number int, comment string) error {
+	path := fmt.Sprintf("%s/merge_requests/%d", p.projectPath(owner, repo), number)
+	if comment != "" {
+		if _, err := p.api.do(ctx, http.MethodPost, path+"/notes", nil, map[string]string{"body": comment}, nil); err != nil {
+			return fmt.Errorf("failed to comment on MR: %v", err)
+		}
+	}
+	if _, err := p.api.do(ctx, http.MethodPut, path, nil, map[string]string{"state_event": "close"}, nil); err != nil {
+		return fmt.Errorf("failed to close MR: %v", err)
+	}
+	return nil
+}
+
+func (p *gitlabProvider) MergeState(ctx context.Context, owner, repo string, number int) (*MergeState, error) {
+	var mr struct {
+		State        string `json:"state"`
+		SHA          string `json:"sha"`
+		HeadPipeline *struct {
+			Status string `json:"status"`
+		} `json:"head_pipeline"`
+	}
+	path := fmt.Sprintf("%s/merge_requests/%d", p.projectPath(owner, repo), number)
+	if _, err := p.api.do(ctx, http.MethodGet, path, nil, nil, &mr); err != nil {
+		return nil, fmt.Errorf("failed to get MR: %v", err)
+	}
+	state := &MergeState{State: gitlabState(mr.State), HeadSHA: mr.SHA, Checks: ChecksNone}
+
+	if mr.HeadPipeline != nil {
+		switch mr.HeadPipeline.Status {
+		case "success":
+			state.Checks = ChecksSuccess
+		case "failed", "canceled":
+			state.Checks = ChecksFailure
+		default:
+			state.Checks = ChecksPending
+		}
+	}
+
+	var approvals struct {
+		ApprovedBy []struct {
+			User gitlabUser `json:"user"`
+		} `json:"approved_by"`
+	}
+	if _, err := p.api.do(ctx, http.MethodGet, path+"/approvals", nil, nil, &approvals); err != nil {
+		return nil, fmt.Errorf("failed to get MR approvals: %v", err)
+	}
+	state.Approvals = len(approvals.ApprovedBy)
+
+	return state, nil
+}
+
+// MergePR merges with the merge method of the project, optionally squashing.
+// Rebasing is a project setting on GitLab, so it cannot be requested per MR.
+func (p *gitlabProvider) MergePR(ctx context.Context, owner, repo string, number int, method, headSHA string) (string, error) {
+	if method == MergeMethodRebase {
+		return "", fmt.Errorf("GitLab does not support the %s merge method per MR, use fast-forward merges in the project settings", method)
+	}
+
+	var mr struct {
+		MergeCommitSHA  string `json:"merge_commit_sha"`
+		SquashCommitSHA string `json:"squash_commit_sha"`
+		SHA             string `json:"sha"`
+	}
+	body := map[string]interface{}{
+		"sha":    headSHA,
+		"squash": method == MergeMethodSquash,
+	}
+	path := fmt.Sprintf("%s/merge_requests/%d/merge", p.projectPath(owner, repo), number)
+	if _, err := p.api.do(ctx, http.MethodPut, path, nil, body, &mr); err != nil {
+		return "", fmt.Errorf("failed to merge MR: %v", err)
+	}
+
+	// Fast-forward merges create no merge commit
+	switch {
+	case mr.MergeCommitSHA != "":
+		return mr.MergeCommitSHA, nil
+	case mr.SquashCommitSHA != "":
+		return mr.SquashCommitSHA, nil
+	default:
+		return mr.SHA, nil
+	}
+}
+
+// MarkReady removes the draft prefix from the MR title
+func (p *gitlabProvider) MarkReady(ctx context.Context, owner, repo string, number int) error {
+	var mr gitlabMergeRequest
+	path := fmt.Sprintf("%s/merge_requests/%d", p.projectPath(owner, repo), number)
+	if _, err := p.api.do(ctx, http.MethodGet, path, nil, nil, &mr); err != nil {
+		return fmt.Errorf("failed to get MR: %v", err)
+	}
+	if !mr.Draft {
+		return nil
+	}
+
+	body := map[string]string{"title": stripDraftPrefix(mr.Title)}
+	if _, err := p.api.do(ctx, http.MethodPut, path, nil, body, nil); err != nil {
+		return fmt.Errorf("failed to mark MR ready: %v", err)
+	}
+	return nil
+}
+
+func (p *gitlabProvider) DefaultBranch(ctx context.Context, owner, repo string) (string, error) {
+	var project struct {
+		DefaultBranch string `json:"default_branch"`
+	}
+	if _, err := p.api.do(ctx, http.MethodGet, p.projectPath(owner, repo), nil, nil, &project); err != nil {
+		return "", fmt.Errorf("failed to get project: %v", err)
+	}
+	return project.DefaultBranch, nil
+}
+
+// FilterRepositoriesByOrg lists the projects of a GitLab group, including subgroups,
+// whose path matches a regex pattern
+func (p *gitlabProvider) FilterRepositoriesByOrg(ctx context.Context, group, pattern string) ([]string, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid repository filter pattern: %v", err)
+	}
+
+	var allRepos []string
+	query := url.Values{
+		"include_subgroups": {"true"},
+		"archived":          {"false"},
+		"per_page":          {"100"},
+		"page":              {"1"},
+	}
+	path := "/groups/" + url.PathEscape(group) + "/projects"
+
+	for {
+		var projects []gitlabProject
+		resp, err := p.api.do(ctx, http.MethodGet, path, query, nil, &projects)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list projects: %v", err)
+		}
+
+		for _, project := range projects {
+			// Skip forks, archived projects
+			if project.ForkedFromProject != nil || project.Archived {
+				continue
+			}
+			if re.MatchString(project.Path) {
+				allRepos = append(allRepos, fmt.Sprintf("%s/%s", p.host, project.PathWithNamespace))
+			}
+		}
+
+		next := resp.Header.Get("X-Next-Page")
+		if next == "" {
+			break
+		}
+		query.Set("page", next)
+	}
+
+	if len(allRepos) == 0 {
+		return nil, fmt.Errorf("no repositories match the filter pattern '%s' in group '%s'",
+			pattern, group)
+	}
+
+	return allRepos, nil
+}
+
+func (p *gitlabProvider) CreatePR(ctx context.Context, owner, repo string, opts PROptions) (*PullRequest, error) {
+	assigneeIDs, err := p.userIDs(ctx, opts.Assignees)
+	if err != nil {
+		return nil, err
+	}
+
+	var existing []gitlabMergeRequest
+	query := url.Values{
+		"state":         {"opened"},
+		"source_branch": {opts.Branch},
+		"target_branch": {opts.Base},
+	}
+	mrPath := p.projectPath(owner, repo) + "/merge_requests"
+	if _, err := p.api.do(ctx, http.MethodGet, mrPath, query, nil, &existing); err != nil {
+		return nil, fmt.Errorf("failed to list MRs: %v", err)
+	}
+
+	// Unknown reviewers are left out with a warning instead of failing the MR
+	var warnings []string
+	reviewerIDs := []int{}
+	for _, reviewer := range opts.Reviewers {
+		ids, err := p.userIDs(ctx, []string{reviewer})
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("could not request review from %s: %v", reviewer, err))
+			continue
+		}
+		reviewerIDs = append(reviewerIDs, ids...)
+	}
+	for _, team := range opts.TeamReviewers {
+		warnings = append(warnings, fmt.Sprintf("could not request review from team %s: GitLab has no team reviewers", team))
+	}
+
+	body := map[string]interface{}{
+		"title":        opts.Title,
+		"description":  opts.Body,
+		"labels":       strings.Join(opts.Labels, ","),
+		"assignee_ids": assigneeIDs,
+		"reviewer_ids": reviewerIDs,
+	}
+
+	var mr gitlabMergeRequest
+	if len(existing) > 0 {
+		// GitLab keeps the draft state in the title
+		if existing[0].Draft {
+			body["title"] = "Draft: " + opts.Title
+		}
+		path := fmt.Sprintf("%s/%d", mrPath, existing[0].IID)
+		if _, err := p.api.do(ctx, http.MethodPut, path, nil, body, &mr); err != nil {
+			return nil, fmt.Errorf("failed to update MR: %v", err)
+		}
+	} else {
+		body["source_branch"] = opts.Branch
+		body["target_branch"] = opts.Base
+		if opts.Draft {
+			body["title"] = "Draft: " + opts.Title
+		}
+		if _, err := p.api.do(ctx, http.MethodPost, mrPath, nil, body, &mr); err != nil {
+			return nil, fmt.Errorf("failed to create MR: %v", err)
+		}
+	}
+
+	return &PullRequest{
+		Number:   mr.IID,
+		URL:      mr.WebURL,
+		State:    gitlabState(mr.State),
+		Draft:    mr.Draft,
+		Warnings: warnings,
+	}, nil
+}
+
+// userIDs resolves usernames to the numeric IDs the merge request API expects
+func (p *gitlabProvider) userIDs(ctx context.Context, usernames []string) ([]int, error) {
+	ids := []int{}
+	for _, username := range usernames {
+		var users []gitlabUser
+		query := url.Values{"username": {username}}
+		if _, err := p.api.do(ctx, http.MethodGet, "/users", query, nil, &users); err != nil {
+			return nil, fmt.Errorf("failed to look up user %s: %v", username, err)
+		}
+		if len(users) == 0 {
+			return nil, fmt.Errorf("unknown GitLab user %s", username)
+		}
+		ids = append(ids, users[0].ID)
+	}
+	return ids, nil
+}
+
+func (p *gitlabProvider) CommitVerification(ctx context.Context, owner, repo, sha string) (*CommitVerification, error) {
+	var signature struct {
+		VerificationStatus string `json:"verification_status"`
+	}
+	path := fmt.Sprintf("%s/repository/commits/%s/signature", p.projectPath(owner, repo), sha)
+	resp, err := p.api.do(ctx, http.MethodGet, path, nil, nil, &signature)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		// GitLab has no signature for unsigned commits
+		return &CommitVerification{Reason: "unsigned"}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get commit signature: %v", err)
+	}
+	return &CommitVerification{
+		Verified: signature.VerificationStatus == "verified",
+		Reason:   signature.VerificationStatus,
+	}, nil
+}
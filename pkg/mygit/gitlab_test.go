@@ -0,0 +1,167 @@
+package mygit
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/nsxbet/proliferate/pkg/types"
+)
+
+const gitlabMRs = "/projects/group%2Frepo/merge_requests"
+
+// newFakeGitLab returns a provider talking to api, which knows the users alice
+// and bob and has no open merge requests
+func newFakeGitLab(t *testing.T) (*gitlabProvider, *fakeAPI) {
+	api := newFakeAPI(t, "PRIVATE-TOKEN", "token")
+	users := map[string]int{"alice": 11, "bob": 12}
+	api.handle("GET /users", func(w http.ResponseWriter, r *http.Request) interface{} {
+		username := r.URL.Query().Get("username")
+		if id, ok := users[username]; ok {
+			return []gitlabUser{{ID: id, Username: username}}
+		}
+		return []gitlabUser{}
+	})
+	api.handle("GET "+gitlabMRs, func(w http.ResponseWriter, r *http.Request) interface{} {
+		if got := r.URL.Query().Get("state"); got != "opened" {
+			t.Errorf("listed MRs with state %q, want opened", got)
+		}
+		return []gitlabMergeRequest{}
+	})
+	api.reply("POST "+gitlabMRs, gitlabMergeRequest{IID: 7, WebURL: "https://gitlab.example.com/group/repo/-/merge_requests/7", State: "opened"})
+
+	return newGitLabProvider(types.ProviderConfig{Host: "gitlab.example.com", BaseURL: api.URL, Token: "token"}), api
+}
+
+func TestGitLabCreatePRMarksDraftWithPrefix(t *testing.T) {
+	p, api := newFakeGitLab(t)
+
+	pr, err := p.CreatePR(context.Background(), "group", "repo", PROptions{
+		Title:         "Bump deps",
+		Body:          "body",
+		Branch:        "bump",
+		Base:          "main",
+		Labels:        []string{"deps", "bot"},
+		Assignees:     []string{"alice"},
+		Reviewers:     []string{"bob", "ghost"},
+		TeamReviewers: []string{"sre"},
+		Draft:         true,
+	})
+	if err != nil {
+		t.Fatalf("CreatePR: %v", err)
+	}
+	if pr.Number != 7 || pr.State != "open" {
+		t.Errorf("got MR %d in state %s, want 7 open", pr.Number, pr.State)
+	}
+	if len(pr.Warnings) != 2 {
+		t.Errorf("got warnings %v, want one for ghost and one for the team", pr.Warnings)
+	}
+
+	// Users are sent by ID, labels as one comma separated string
+	want := map[string]interface{}{
+		"title":         "Draft: Bump deps",
+		"description":   "body",
+		"labels":        "deps,bot",
+		"assignee_ids":  []interface{}{11.0},
+		"reviewer_ids":  []interface{}{12.0},
+		"source_branch": "bump",
+		"target_branch": "main",
+	}
+	if got := api.received("POST " + gitlabMRs); !reflect.DeepEqual(got, want) {
+		t.Errorf("created MR with %v, want %v", got, want)
+	}
+}
+
+func TestGitLabUpdateKeepsDraftPrefix(t *testing.T) {
+	p, api := newFakeGitLab(t)
+	api.reply("GET "+gitlabMRs, []gitlabMergeRequest{{IID: 3, State: "opened", Draft: true}})
+	api.reply("PUT "+gitlabMRs+"/3", gitlabMergeRequest{IID: 3, State: "opened", Draft: true})
+
+	pr, err := p.CreatePR(context.Background(), "group", "repo", PROptions{Title: "Bump deps", Branch: "bump", Base: "main"})
+	if err != nil {
+		t.Fatalf("CreatePR: %v", err)
+	}
+	if pr.Number != 3 || !pr.Draft {
+		t.Errorf("got MR %d draft=%v, want 3 draft", pr.Number, pr.Draft)
+	}
+	if api.received("POST "+gitlabMRs) != nil {
+		t.Errorf("created a second MR")
+	}
+	updated := api.received("PUT " + gitlabMRs + "/3")
+	if _, ok := updated["source_branch"]; ok {
+		t.Errorf("update sent the source branch")
+	}
+	if got := updated["title"]; got != "Draft: Bump deps" {
+		t.Errorf("updated title to %q", got)
+	}
+}
+
+func TestGitLabUnknownAssignee(t *testing.T) {
+	p, _ := newFakeGitLab(t)
+
+	_, err := p.CreatePR(context.Background(), "group", "repo", PROptions{Branch: "bump", Base: "main", Assignees: []string{"ghost"}})
+	if err == nil {
+		t.Fatal("CreatePR succeeded with an unknown assignee")
+	}
+}
+
+func TestGitLabLockedMRIsOpen(t *testing.T) {
+	for state, want := range map[string]string{
+		"opened": "open",
+		"locked": "open",
+		"closed": "closed",
+		"merged": "merged",
+	} {
+		p, api := newFakeGitLab(t)
+		api.reply("GET "+gitlabMRs+"/3", gitlabMergeRequest{IID: 3, State: state})
+
+		got, err := p.GetPRStatus(context.Background(), "group", "repo", 3)
+		if err != nil {
+			t.Fatalf("GetPRStatus: %v", err)
+		}
+		if got != want {
+			t.Errorf("state %s mapped to %s, want %s", state, got, want)
+		}
+	}
+}
+
+func TestGitLabListsSubgroupProjects(t *testing.T) {
+	p, api := newFakeGitLab(t)
+	pages := [][]gitlabProject{
+		{
+			{Path: "svc-a", PathWithNamespace: "parent/group/svc-a"},
+			{Path: "lib", PathWithNamespace: "parent/group/lib"},
+			{Path: "svc-fork", PathWithNamespace: "parent/group/svc-fork", ForkedFromProject: &struct{}{}},
+		},
+		{
+			{Path: "svc-b", PathWithNamespace: "parent/group/sub/svc-b"},
+			{Path: "svc-old", PathWithNamespace: "parent/group/svc-old", Archived: true},
+		},
+	}
+	api.handle("GET /groups/parent%2Fgroup/projects", func(w http.ResponseWriter, r *http.Request) interface{} {
+		if r.URL.Query().Get("include_subgroups") != "true" {
+			t.Errorf("listed projects without subgroups")
+		}
+		var page int
+		fmt.Sscan(r.URL.Query().Get("page"), &page)
+		if page < len(pages) {
+			w.Header().Set("X-Next-Page", fmt.Sprint(page+1))
+		}
+		return pages[page-1]
+	})
+
+	repos, err := p.FilterRepositoriesByOrg(context.Background(), "parent/group", "^svc-")
+	if err != nil {
+		t.Fatalf("FilterRepositoriesByOrg: %v", err)
+	}
+	want := []string{"gitlab.example.com/parent/group/svc-a", "gitlab.example.com/parent/group/sub/svc-b"}
+	if !reflect.DeepEqual(repos, want) {
+		t.Errorf("got %v, want %v", repos, want)
+	}
+
+	if _, err := p.FilterRepositoriesByOrg(context.Background(), "parent/group", "^nothing$"); err == nil {
+		t.Error("expected an error when no project matches")
+	}
+}
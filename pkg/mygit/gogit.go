@@ -0,0 +1,928 @@
+package mygit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/cache"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	fdiff "github.com/go-git/go-git/v5/plumbing/format/diff"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+	"github.com/go-git/go-git/v5/storage/memory"
+	gobinary "github.com/go-git/go-git/v5/utils/binary"
+	"github.com/go-git/go-git/v5/utils/diff"
+	"github.com/sergi/go-diff/diffmatchpatch"
+
+	"github.com/nsxbet/proliferate/pkg/redact"
+)
+
+// goGitBackend runs git in process with go-git, so no git binary is needed.
+// It works on any billy filesystem, which lets it run on in-memory clones.
+// It does not use the clone cache, partial clone filters or commit signing.
+type goGitBackend struct {
+	*Git
+	fs billy.Filesystem
+}
+
+// proliferateSection holds the repository of a clone in its git config, under
+// the same key as the exec backend
+const proliferateSection = "proliferate"
+
+func newGoGitBackend(g *Git, fs billy.Filesystem) *goGitBackend {
+	if fs == nil {
+		fs = osfs.New("/")
+	}
+	return &goGitBackend{Git: g, fs: fs}
+}
+
+func (b *goGitBackend) open(dir string) (*git.Repository, error) {
+	storage, worktree, err := b.chroot(dir)
+	if err != nil {
+		return nil, err
+	}
+	repo, err := git.Open(storage, worktree)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open repository %s: %v", dir, err)
+	}
+	return repo, nil
+}
+
+// chroot returns the object storage and the working tree of the clone in dir
+func (b *goGitBackend) chroot(dir string) (*filesystem.Storage, billy.Filesystem, error) {
+	worktree, err := b.fs.Chroot(dir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open %s: %v", dir, err)
+	}
+	dot, err := worktree.Chroot(git.GitDirName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open %s: %v", dir, err)
+	}
+	return filesystem.NewStorage(dot, cache.NewObjectLRUDefault()), worktree, nil
+}
+
+// auth returns the credentials of the provider of repo for git over HTTPS
+func (b *goGitBackend) auth(repo string) (*http.BasicAuth, error) {
+	host, owner, _, err := b.ParseRepoString(repo)
+	if err != nil {
+		return nil, err
+	}
+	p, err := b.providerFor(host)
+	if err != nil {
+		return nil, err
+	}
+
+	username, password, err := p.Credentials(context.Background(), owner)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get credentials for %s: %v", repo, err)
+	}
+	redact.Register(password)
+	return &http.BasicAuth{Username: username, Password: password}, nil
+}
+
+func (b *goGitBackend) Clone(repo string, opts CloneOptions) (string, error) {
+	if opts.Filter != "" {
+		return "", fmt.Errorf("partial clone filters are not supported by the go-git backend")
+	}
+	// go-git reports the files outside a sparse checkout as deleted
+	if len(opts.SparsePaths) > 0 {
+		return "", fmt.Errorf("sparse checkouts are not supported by the go-git backend")
+	}
+
+	cloneURL, err := b.cloneURL(repo)
+	if err != nil {
+		return "", err
+	}
+	auth, err := b.auth(repo)
+	if err != nil {
+		return "", err
+	}
+
+	tmpDir, err := util.TempDir(b.fs, os.TempDir(), "proliferate-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp directory: %v", err)
+	}
+
+	cloneOpts := &git.CloneOptions{
+		URL:          cloneURL,
+		Auth:         auth,
+		Depth:        opts.Depth,
+		SingleBranch: opts.Depth > 0,
+	}
+	if opts.Branch != "" {
+		cloneOpts.ReferenceName = plumbing.NewBranchReferenceName(opts.Branch)
+	}
+	if err := b.clone(tmpDir, repo, cloneOpts); err != nil {
+		util.RemoveAll(b.fs, tmpDir)
+		return "", err
+	}
+	return tmpDir, nil
+}
+
+func (b *goGitBackend) clone(dir string, repo string, cloneOpts *git.CloneOptions) error {
+	storage, worktree, err := b.chroot(dir)
+	if err != nil {
+		return err
+	}
+	r, err := git.Clone(storage, worktree, cloneOpts)
+	if err != nil {
+		return fmt.Errorf("failed to clone repository: %s", redact.String(err.Error()))
+	}
+	head, err := r.Head()
+	if err != nil {
+		return fmt.Errorf("failed to read HEAD: %v", err)
+	}
+
+	err = b.updateConfig(r, func(cfg *config.Config) {
+		cfg.Raw.Section(proliferateSection).SetOption("repo", repo)
+		// go-git tracks HEAD itself when cloning a single branch without
+		// naming it, track the default branch instead like git does
+		if origin := cfg.Remotes["origin"]; cloneOpts.SingleBranch && cloneOpts.ReferenceName == plumbing.HEAD && origin != nil {
+			origin.Fetch = []config.RefSpec{config.RefSpec(fmt.Sprintf("+%s:refs/remotes/origin/%s", head.Name(), head.Name().Short()))}
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if cloneOpts.SingleBranch && cloneOpts.ReferenceName == plumbing.HEAD {
+		ref := plumbing.NewHashReference(plumbing.NewRemoteReferenceName("origin", head.Name().Short()), head.Hash())
+		if err := r.Storer.SetReference(ref); err != nil {
+			return fmt.Errorf("failed to track %s: %v", head.Name().Short(), err)
+		}
+	}
+	return nil
+}
+
+func (b *goGitBackend) updateConfig(r *git.Repository, update func(*config.Config)) error {
+	cfg, err := r.Config()
+	if err != nil {
+		return fmt.Errorf("failed to read git config: %v", err)
+	}
+	update(cfg)
+	if err := r.SetConfig(cfg); err != nil {
+		return fmt.Errorf("failed to write git config: %v", err)
+	}
+	return nil
+}
+
+func (b *goGitBackend) checkout(r *git.Repository, opts *git.CheckoutOptions) error {
+	w, err := r.Worktree()
+	if err != nil {
+		return err
+	}
+	if err := w.Checkout(opts); err != nil {
+		return fmt.Errorf("failed to check out %s: %v", opts.Branch.Short(), err)
+	}
+	return nil
+}
+
+// remoteRepo returns the repository string behind a remote of a clone
+func (b *goGitBackend) remoteRepo(r *git.Repository, remote string) (string, error) {
+	cfg, err := r.Config()
+	if err != nil {
+		return "", fmt.Errorf("failed to read git config: %v", err)
+	}
+
+	repo := cfg.Raw.Section(proliferateSection).Option("repo")
+	if remote != "origin" {
+		repo = cfg.Raw.Section("remote").Subsection(remote).Option("proliferateRepo")
+	}
+	if repo == "" {
+		return "", fmt.Errorf("failed to read repository of remote %s", remote)
+	}
+	return repo, nil
+}
+
+func (b *goGitBackend) AddRemote(dir string, name string, repo string) error {
+	r, err := b.open(dir)
+	if err != nil {
+		return err
+	}
+	remoteURL, err := b.cloneURL(repo)
+	if err != nil {
+		return err
+	}
+
+	if _, err := r.CreateRemote(&config.RemoteConfig{Name: name, URLs: []string{remoteURL}}); err != nil {
+		return fmt.Errorf("failed to add remote %s: %v", name, err)
+	}
+	return b.updateConfig(r, func(cfg *config.Config) {
+		cfg.Raw.Section("remote").Subsection(name).SetOption("proliferateRepo", repo)
+	})
+}
+
+// RemoteHead returns the branch checked out by the clone, which go-git takes
+// from the default branch advertised by origin
+func (b *goGitBackend) RemoteHead(dir string) (string, error) {
+	r, err := b.open(dir)
+	if err != nil {
+		return "", err
+	}
+	head, err := r.Head()
+	if err != nil {
+		return "", fmt.Errorf("failed to read HEAD: %v", err)
+	}
+	if !head.Name().IsBranch() {
+		return "", fmt.Errorf("HEAD of %s is not a branch", dir)
+	}
+	return head.Name().Short(), nil
+}
+
+func (b *goGitBackend) CreateBranch(dir string, branch string, base string) error {
+	r, err := b.open(dir)
+	if err != nil {
+		return err
+	}
+	baseRef, err := r.Reference(plumbing.NewRemoteReferenceName("origin", base), true)
+	if err != nil {
+		return fmt.Errorf("failed to create branch: base branch %s not found: %v", base, err)
+	}
+
+	return b.checkout(r, &git.CheckoutOptions{
+		Hash:   baseRef.Hash(),
+		Branch: plumbing.NewBranchReferenceName(branch),
+		Create: true,
+	})
+}
+
+func (b *goGitBackend) FetchRemoteBranch(dir string, remote string, branch string) (string, error) {
+	r, err := b.open(dir)
+	if err != nil {
+		return "", err
+	}
+	repo, err := b.remoteRepo(r, remote)
+	if err != nil {
+		return "", err
+	}
+	auth, err := b.auth(repo)
+	if err != nil {
+		return "", err
+	}
+	rem, err := r.Remote(remote)
+	if err != nil {
+		return "", fmt.Errorf("failed to find remote %s: %v", remote, err)
+	}
+
+	refs, err := rem.List(&git.ListOptions{Auth: auth})
+	if err != nil {
+		return "", fmt.Errorf("failed to list remote branch: %s", redact.String(err.Error()))
+	}
+	var head string
+	for _, ref := range refs {
+		if ref.Name() == plumbing.NewBranchReferenceName(branch) {
+			head = ref.Hash().String()
+		}
+	}
+	if head == "" {
+		return "", nil
+	}
+
+	fetchOpts := &git.FetchOptions{
+		RemoteName: remote,
+		RefSpecs:   []config.RefSpec{config.RefSpec(fmt.Sprintf("+refs/heads/%s:refs/remotes/%s/%s", branch, remote, branch))},
+		Auth:       auth,
+	}
+	// Keep shallow clones shallow
+	if shallow, err := r.Storer.Shallow(); err == nil && len(shallow) > 0 {
+		fetchOpts.Depth = shallowFetchDepth
+	}
+	if err := r.Fetch(fetchOpts); err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return "", fmt.Errorf("failed to fetch remote branch: %s", redact.String(err.Error()))
+	}
+	return head, nil
+}
+
+// ForeignCommits walks the branch down to where it meets base, returning the
+// foreign commits oldest first. Shallow clones are not deepened, so the
+// branches must meet within the cloned history.
+func (b *goGitBackend) ForeignCommits(dir string, base string, remote string, branch string, ours []string) ([]BranchCommit, error) {
+	r, err := b.open(dir)
+	if err != nil {
+		return nil, err
+	}
+	baseCommit, err := b.remoteCommit(r, "origin", base)
+	if err != nil {
+		return nil, err
+	}
+	branchCommit, err := b.remoteCommit(r, remote, branch)
+	if err != nil {
+		return nil, err
+	}
+
+	mergeBases, err := branchCommit.MergeBase(baseCommit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the merge base of %s and %s: %v", base, branch, err)
+	}
+	if shallow, err := r.Storer.Shallow(); err == nil && len(shallow) > 0 && len(mergeBases) == 0 {
+		return nil, fmt.Errorf("branches %s and %s do not meet within the shallow clone, increase checkout.depth", base, branch)
+	}
+	stop := make(map[plumbing.Hash]bool)
+	for _, c := range mergeBases {
+		stop[c.Hash] = true
+	}
+
+	known := make(map[string]bool)
+	for _, sha := range ours {
+		known[sha] = true
+	}
+	authorEmail := b.config.GetAuthorEmail()
+
+	var commits []*object.Commit
+	err = object.NewCommitPreorderIter(branchCommit, stop, nil).ForEach(func(c *object.Commit) error {
+		commits = append(commits, c)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list branch commits: %v", err)
+	}
+
+	var foreign []BranchCommit
+	for _, c := range parentsFirst(commits) {
+		sha, email := c.Hash.String(), c.Committer.Email
+		if known[sha] || (authorEmail != "" && email == authorEmail) {
+			continue
+		}
+		foreign = append(foreign, BranchCommit{
+			SHA:            sha,
+			Subject:        strings.SplitN(c.Message, "\n", 2)[0],
+			CommitterName:  c.Committer.Name,
+			CommitterEmail: email,
+			CommitterDate:  c.Committer.When,
+			Merge:          c.NumParents() > 1,
+		})
+	}
+	return foreign, nil
+}
+
+// parentsFirst orders commits so that each one comes after its parents
+func parentsFirst(commits []*object.Commit) []*object.Commit {
+	listed := make(map[plumbing.Hash]*object.Commit)
+	for _, c := range commits {
+		listed[c.Hash] = c
+	}
+
+	var ordered []*object.Commit
+	done := make(map[plumbing.Hash]bool)
+	var visit func(c *object.Commit)
+	visit = func(c *object.Commit) {
+		if done[c.Hash] {
+			return
+		}
+		done[c.Hash] = true
+		for _, parent := range c.ParentHashes {
+			if p, ok := listed[parent]; ok {
+				visit(p)
+			}
+		}
+		ordered = append(ordered, c)
+	}
+	for i := len(commits) - 1; i >= 0; i-- {
+		visit(commits[i])
+	}
+	return ordered
+}
+
+func (b *goGitBackend) remoteCommit(r *git.Repository, remote string, branch string) (*object.Commit, error) {
+	ref, err := r.Reference(plumbing.NewRemoteReferenceName(remote, branch), true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find %s/%s: %v", remote, branch, err)
+	}
+	commit, err := r.CommitObject(ref.Hash())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s/%s: %v", remote, branch, err)
+	}
+	return commit, nil
+}
+
+// CherryPick replays commits on the checked out branch in order, keeping
+// their author and committer. Unlike git it does not merge within files: a
+// commit only applies when the files it touches still match its parent.
+func (b *goGitBackend) CherryPick(dir string, commits []BranchCommit) error {
+	if b.SigningEnabled() {
+		return fmt.Errorf("commit signing is not supported by the go-git backend")
+	}
+
+	r, err := b.open(dir)
+	if err != nil {
+		return err
+	}
+	w, err := r.Worktree()
+	if err != nil {
+		return err
+	}
+	_, worktree, err := b.chroot(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, bc := range commits {
+		if bc.Merge {
+			return fmt.Errorf("cannot rebase merge commit %s", bc)
+		}
+		if err := b.cherryPick(r, w, worktree, plumbing.NewHash(bc.SHA)); err != nil {
+			return fmt.Errorf("failed to rebase commit %s: %v", bc, err)
+		}
+	}
+	return nil
+}
+
+func (b *goGitBackend) cherryPick(r *git.Repository, w *git.Worktree, worktree billy.Filesystem, hash plumbing.Hash) error {
+	commit, err := r.CommitObject(hash)
+	if err != nil {
+		return err
+	}
+	parent, err := commit.Parent(0)
+	if err != nil {
+		return err
+	}
+	head, err := r.Head()
+	if err != nil {
+		return err
+	}
+	headCommit, err := r.CommitObject(head.Hash())
+	if err != nil {
+		return err
+	}
+
+	trees := make([]*object.Tree, 3)
+	for i, c := range []*object.Commit{parent, commit, headCommit} {
+		if trees[i], err = c.Tree(); err != nil {
+			return err
+		}
+	}
+	changes, err := object.DiffTree(trees[0], trees[1])
+	if err != nil {
+		return err
+	}
+
+	for _, change := range changes {
+		for _, path := range []string{change.From.Name, change.To.Name} {
+			if path == "" {
+				continue
+			}
+			if !sameEntry(trees[0], trees[2], path) && !sameEntry(trees[1], trees[2], path) {
+				return fmt.Errorf("%s was changed on the base branch too", path)
+			}
+		}
+	}
+
+	for _, change := range changes {
+		if change.From.Name != "" && change.From.Name != change.To.Name {
+			if _, err := w.Remove(change.From.Name); err != nil {
+				return err
+			}
+		}
+		if change.To.Name == "" {
+			continue
+		}
+		file, err := trees[1].File(change.To.Name)
+		if err != nil {
+			return err
+		}
+		if err := writeFile(worktree, file); err != nil {
+			return err
+		}
+		if _, err := w.Add(change.To.Name); err != nil {
+			return err
+		}
+	}
+
+	_, err = w.Commit(commit.Message, &git.CommitOptions{
+		Author:            &commit.Author,
+		Committer:         &commit.Committer,
+		AllowEmptyCommits: true,
+	})
+	return err
+}
+
+// sameEntry reports whether path has the same content and mode in both trees,
+// or is missing from both
+func sameEntry(a, b *object.Tree, path string) bool {
+	x, errA := a.FindEntry(path)
+	y, errB := b.FindEntry(path)
+	if errA != nil || errB != nil {
+		return errA != nil && errB != nil
+	}
+	return x.Hash == y.Hash && x.Mode == y.Mode
+}
+
+// writeFile writes a file of a commit to the working tree
+func writeFile(worktree billy.Filesystem, file *object.File) error {
+	contents, err := file.Contents()
+	if err != nil {
+		return err
+	}
+	if file.Mode == filemode.Symlink {
+		worktree.Remove(file.Name)
+		return worktree.Symlink(contents, file.Name)
+	}
+
+	mode, err := file.Mode.ToOSFileMode()
+	if err != nil {
+		return err
+	}
+	return util.WriteFile(worktree, file.Name, []byte(contents), mode.Perm())
+}
+
+// Diff stages every change and renders the diff stat like git diff --cached --stat
+func (b *goGitBackend) Diff(dir string) (string, error) {
+	if err := b.Add(dir); err != nil {
+		return "", err
+	}
+	changes, err := b.stagedChanges(dir, plumbing.HEAD)
+	if err != nil {
+		return "", err
+	}
+	return diffStat(changes), nil
+}
+
+func (b *goGitBackend) DiffRemoteBranch(dir string, remote string, branch string) (string, error) {
+	changes, err := b.stagedChanges(dir, plumbing.NewRemoteReferenceName(remote, branch))
+	if err != nil {
+		return "", err
+	}
+	return diffStat(changes), nil
+}
+
+func diffStat(changes []stagedChange) string {
+	if len(changes) == 0 {
+		return ""
+	}
+
+	var stats object.FileStats
+	var additions, deletions int
+	for _, change := range changes {
+		stat := object.FileStat{Name: change.path}
+		if change.binary() {
+			stats = append(stats, stat)
+			continue
+		}
+		for _, d := range diff.Do(change.before, change.after) {
+			lines := strings.Count(d.Text, "\n")
+			if !strings.HasSuffix(d.Text, "\n") {
+				lines++
+			}
+			switch d.Type {
+			case diffmatchpatch.DiffInsert:
+				stat.Addition += lines
+			case diffmatchpatch.DiffDelete:
+				stat.Deletion += lines
+			}
+		}
+		additions += stat.Addition
+		deletions += stat.Deletion
+		stats = append(stats, stat)
+	}
+
+	summary := fmt.Sprintf(" %d %s changed", len(stats), plural(len(stats), "file", "files"))
+	if additions > 0 {
+		summary += fmt.Sprintf(", %d %s(+)", additions, plural(additions, "insertion", "insertions"))
+	}
+	if deletions > 0 {
+		summary += fmt.Sprintf(", %d %s(-)", deletions, plural(deletions, "deletion", "deletions"))
+	}
+
+	lines := strings.Split(strings.TrimSpace(stats.String()+summary), "\n")
+	return strings.Join(lines, "\n ")
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
+
+func (b *goGitBackend) Patch(dir string) (string, error) {
+	changes, err := b.stagedChanges(dir, plumbing.HEAD)
+	if err != nil {
+		return "", err
+	}
+
+	var patch unifiedPatch
+	for _, change := range changes {
+		fp := &filePatch{from: change.from, to: change.to, binary: change.binary()}
+		if !fp.binary {
+			for _, d := range diff.Do(change.before, change.after) {
+				fp.chunks = append(fp.chunks, patchChunk{content: d.Text, op: chunkOperations[d.Type]})
+			}
+		}
+		patch = append(patch, fp)
+	}
+
+	var buf bytes.Buffer
+	if err := fdiff.NewUnifiedEncoder(&buf, fdiff.DefaultContextLines).Encode(patch); err != nil {
+		return "", fmt.Errorf("failed to render patch: %v", err)
+	}
+	return buf.String(), nil
+}
+
+// stagedChange is a file that differs between a commit and the index
+type stagedChange struct {
+	path          string
+	from, to      *patchFile
+	before, after string
+}
+
+// stagedChanges compares the index with the commit ref points to, sorted by
+// path. Only HEAD may be missing, in a repository without commits.
+func (b *goGitBackend) stagedChanges(dir string, ref plumbing.ReferenceName) ([]stagedChange, error) {
+	r, err := b.open(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	committed := make(map[string]*object.File)
+	reference, err := r.Reference(ref, true)
+	switch {
+	case err == nil:
+		commit, err := r.CommitObject(reference.Hash())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", ref.Short(), err)
+		}
+		files, err := commit.Files()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", ref.Short(), err)
+		}
+		err = files.ForEach(func(f *object.File) error {
+			committed[f.Name] = f
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", ref.Short(), err)
+		}
+	case ref != plumbing.HEAD || !errors.Is(err, plumbing.ErrReferenceNotFound):
+		return nil, fmt.Errorf("failed to find %s: %v", ref.Short(), err)
+	}
+
+	idx, err := r.Storer.Index()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index: %v", err)
+	}
+
+	var changes []stagedChange
+	for _, entry := range idx.Entries {
+		file, ok := committed[entry.Name]
+		delete(committed, entry.Name)
+		if ok && file.Hash == entry.Hash && file.Mode == entry.Mode {
+			continue
+		}
+
+		change := stagedChange{path: entry.Name, to: &patchFile{path: entry.Name, hash: entry.Hash, mode: entry.Mode}}
+		if ok {
+			change.from = &patchFile{path: file.Name, hash: file.Hash, mode: file.Mode}
+			if change.before, err = file.Contents(); err != nil {
+				return nil, fmt.Errorf("failed to read %s: %v", file.Name, err)
+			}
+		}
+		if change.after, err = blobContents(r, entry.Hash); err != nil {
+			return nil, fmt.Errorf("failed to read %s from the index: %v", entry.Name, err)
+		}
+		changes = append(changes, change)
+	}
+
+	// Whatever the index did not list was deleted
+	for path, file := range committed {
+		change := stagedChange{path: path, from: &patchFile{path: path, hash: file.Hash, mode: file.Mode}}
+		if change.before, err = file.Contents(); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", path, err)
+		}
+		changes = append(changes, change)
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].path < changes[j].path })
+	return changes, nil
+}
+
+func blobContents(r *git.Repository, hash plumbing.Hash) (string, error) {
+	blob, err := r.BlobObject(hash)
+	if err != nil {
+		return "", err
+	}
+	reader, err := blob.Reader()
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+func (c stagedChange) binary() bool {
+	for _, content := range []string{c.before, c.after} {
+		if binary, _ := gobinary.IsBinary(strings.NewReader(content)); binary {
+			return true
+		}
+	}
+	return false
+}
+
+// unifiedPatch lets go-git render staged changes, which it only diffs between
+// commits itself
+type unifiedPatch []fdiff.FilePatch
+
+func (p unifiedPatch) FilePatches() []fdiff.FilePatch { return p }
+func (p unifiedPatch) Message() string                { return "" }
+
+type filePatch struct {
+	from, to *patchFile
+	binary   bool
+	chunks   []fdiff.Chunk
+}
+
+func (p *filePatch) IsBinary() bool        { return p.binary }
+func (p *filePatch) Chunks() []fdiff.Chunk { return p.chunks }
+
+func (p *filePatch) Files() (fdiff.File, fdiff.File) {
+	// A nil *patchFile would not be a nil fdiff.File
+	var from, to fdiff.File
+	if p.from != nil {
+		from = p.from
+	}
+	if p.to != nil {
+		to = p.to
+	}
+	return from, to
+}
+
+type patchFile struct {
+	path string
+	hash plumbing.Hash
+	mode filemode.FileMode
+}
+
+func (f *patchFile) Hash() plumbing.Hash     { return f.hash }
+func (f *patchFile) Mode() filemode.FileMode { return f.mode }
+func (f *patchFile) Path() string            { return f.path }
+
+type patchChunk struct {
+	content string
+	op      fdiff.Operation
+}
+
+func (c patchChunk) Content() string       { return c.content }
+func (c patchChunk) Type() fdiff.Operation { return c.op }
+
+var chunkOperations = map[diffmatchpatch.Operation]fdiff.Operation{
+	diffmatchpatch.DiffEqual:  fdiff.Equal,
+	diffmatchpatch.DiffInsert: fdiff.Add,
+	diffmatchpatch.DiffDelete: fdiff.Delete,
+}
+
+func (b *goGitBackend) Add(dir string) error {
+	r, err := b.open(dir)
+	if err != nil {
+		return err
+	}
+	w, err := r.Worktree()
+	if err != nil {
+		return err
+	}
+	if err := w.AddWithOptions(&git.AddOptions{All: true}); err != nil {
+		return fmt.Errorf("failed to add changes: %v", err)
+	}
+	return nil
+}
+
+func (b *goGitBackend) Commit(dir string, message string, opts CommitOptions) error {
+	if b.SigningEnabled() {
+		return fmt.Errorf("commit signing is not supported by the go-git backend")
+	}
+
+	r, err := b.open(dir)
+	if err != nil {
+		return err
+	}
+	w, err := r.Worktree()
+	if err != nil {
+		return err
+	}
+
+	committer, err := b.committer()
+	if err != nil {
+		return err
+	}
+	author := committer
+	if opts.Author != nil {
+		if opts.Author.Name == "" || opts.Author.Email == "" {
+			return fmt.Errorf("commitAuthor needs both a name and an email")
+		}
+		author = &object.Signature{Name: opts.Author.Name, Email: opts.Author.Email, When: committer.When}
+	}
+	trailers, err := coAuthorTrailers(opts)
+	if err != nil {
+		return err
+	}
+	if trailers != "" {
+		message = strings.TrimRight(message, "\n") + "\n\n" + trailers
+	}
+	// git ends commit messages with a newline
+	message = strings.TrimRight(message, "\n") + "\n"
+
+	if _, err := w.Commit(message, &git.CommitOptions{Author: author, Committer: committer}); err != nil {
+		return fmt.Errorf("failed to commit changes: %v", err)
+	}
+	return nil
+}
+
+// committer is the configured author, falling back to the global git config
+func (b *goGitBackend) committer() (*object.Signature, error) {
+	name, email := b.config.GetAuthorName(), b.config.GetAuthorEmail()
+	if name == "" || email == "" {
+		global, err := config.LoadConfig(config.GlobalScope)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the global git config: %v", err)
+		}
+		if name == "" {
+			name = global.User.Name
+		}
+		if email == "" {
+			email = global.User.Email
+		}
+	}
+	if name == "" || email == "" {
+		return nil, fmt.Errorf("author-name and author-email are required by the go-git backend")
+	}
+	return &object.Signature{Name: name, Email: email, When: time.Now()}, nil
+}
+
+func (b *goGitBackend) Push(dir string, remote string, branch string, expectedHead string) error {
+	r, err := b.open(dir)
+	if err != nil {
+		return err
+	}
+	repo, err := b.remoteRepo(r, remote)
+	if err != nil {
+		return err
+	}
+	auth, err := b.auth(repo)
+	if err != nil {
+		return err
+	}
+
+	ref := plumbing.NewBranchReferenceName(branch)
+	pushOpts := &git.PushOptions{
+		RemoteName: remote,
+		RefSpecs:   []config.RefSpec{config.RefSpec(fmt.Sprintf("%s:%s", ref, ref))},
+		Auth:       auth,
+	}
+	if expectedHead != "" {
+		pushOpts.RefSpecs = []config.RefSpec{config.RefSpec(fmt.Sprintf("+%s:%s", ref, ref))}
+		pushOpts.ForceWithLease = &git.ForceWithLease{RefName: ref, Hash: plumbing.NewHash(expectedHead)}
+	}
+	if err := r.Push(pushOpts); err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return fmt.Errorf("failed to push changes: %s", redact.String(err.Error()))
+	}
+	return nil
+}
+
+func (b *goGitBackend) GetCommitID(dir string) (string, error) {
+	r, err := b.open(dir)
+	if err != nil {
+		return "", err
+	}
+	head, err := r.Head()
+	if err != nil {
+		return "", fmt.Errorf("failed to get commit ID: %v", err)
+	}
+	return head.Hash().String(), nil
+}
+
+func (b *goGitBackend) DeleteRemoteBranch(repo string, branch string) error {
+	remoteURL, err := b.cloneURL(repo)
+	if err != nil {
+		return err
+	}
+	auth, err := b.auth(repo)
+	if err != nil {
+		return err
+	}
+
+	rem := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{Name: "origin", URLs: []string{remoteURL}})
+	err = rem.Push(&git.PushOptions{
+		RefSpecs: []config.RefSpec{config.RefSpec(":" + plumbing.NewBranchReferenceName(branch).String())},
+		Auth:     auth,
+	})
+	// Deleting a branch that does not exist has nothing to push
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return fmt.Errorf("failed to delete branch %s: %s", branch, redact.String(err.Error()))
+	}
+	return nil
+}
@@ -0,0 +1,421 @@
+package mygit
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	"github.com/go-git/go-git/v5/plumbing/transport/file"
+	"github.com/go-git/go-git/v5/plumbing/transport/server"
+	"github.com/go-git/go-git/v5/storage/memory"
+
+	"github.com/nsxbet/proliferate/pkg/types"
+)
+
+const testRepo = "git.local/org/repo"
+
+// testConfig configures a single Gitea provider whose clone URLs point to
+// local bare repositories
+type testConfig struct {
+	baseURL string
+}
+
+func (c testConfig) GetGithubToken() string { return "" }
+func (c testConfig) GetAuthorEmail() string { return "bot@example.com" }
+func (c testConfig) GetAuthorName() string  { return "proliferate" }
+func (c testConfig) GetRepos() []string     { return nil }
+func (c testConfig) GetGitBackend() string  { return types.GitBackendGoGit }
+func (c testConfig) GetSigning() *types.SigningConfig {
+	return nil
+}
+func (c testConfig) GetCache() *types.CacheConfig {
+	return &types.CacheConfig{Disabled: true}
+}
+func (c testConfig) GetGithubApp() *types.GitHubAppConfig {
+	return nil
+}
+func (c testConfig) GetProviders() []types.ProviderConfig {
+	return []types.ProviderConfig{{Host: "git.local", Type: ProviderGitea, BaseURL: c.baseURL, Token: "token"}}
+}
+
+// testRemote is a bare repository served in process over file:// URLs, with
+// a working copy to push commits made by others
+type testRemote struct {
+	t        *testing.T
+	bare     *git.Repository
+	upstream *git.Repository
+}
+
+// newTestBackend returns a go-git backend cloning into memory from a bare
+// repository whose master branch holds a README
+func newTestBackend(t *testing.T) (*goGitBackend, *testRemote) {
+	// Serve file:// URLs with go-git itself, so no git binary is needed
+	client.InstallProtocol("file", server.NewClient(server.DefaultLoader))
+	t.Cleanup(func() { client.InstallProtocol("file", file.DefaultClient) })
+
+	root := t.TempDir()
+	bare, err := git.PlainInit(filepath.Join(root, "org", "repo.git"), true)
+	if err != nil {
+		t.Fatalf("failed to create the remote: %v", err)
+	}
+	upstream, err := git.Init(memory.NewStorage(), memfs.New())
+	if err != nil {
+		t.Fatalf("failed to create the upstream working copy: %v", err)
+	}
+	_, err = upstream.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{"file://" + filepath.Join(root, "org", "repo.git")}})
+	if err != nil {
+		t.Fatalf("failed to add the remote: %v", err)
+	}
+
+	remote := &testRemote{t: t, bare: bare, upstream: upstream}
+	remote.commit("README.md", "# repo\n", "Initial commit", "alice")
+	remote.push("master")
+
+	g, err := NewGit(testConfig{baseURL: "file://" + root})
+	if err != nil {
+		t.Fatalf("NewGit: %v", err)
+	}
+	return newGoGitBackend(g, memfs.New()), remote
+}
+
+// commit commits a file to the checked out branch of the upstream working copy
+func (r *testRemote) commit(name, contents, message, who string) plumbing.Hash {
+	w, err := r.upstream.Worktree()
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	if err := util.WriteFile(w.Filesystem, name, []byte(contents), 0o644); err != nil {
+		r.t.Fatal(err)
+	}
+	if _, err := w.Add(name); err != nil {
+		r.t.Fatal(err)
+	}
+	sig := &object.Signature{Name: who, Email: who + "@example.com", When: time.Now()}
+	hash, err := w.Commit(message, &git.CommitOptions{Author: sig, Committer: sig})
+	if err != nil {
+		r.t.Fatalf("failed to commit %s: %v", name, err)
+	}
+	return hash
+}
+
+// checkout checks out a branch of the remote in the upstream working copy
+func (r *testRemote) checkout(branch string) {
+	err := r.upstream.Fetch(&git.FetchOptions{RefSpecs: []config.RefSpec{"+refs/heads/*:refs/remotes/origin/*"}})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		r.t.Fatalf("failed to fetch: %v", err)
+	}
+	ref, err := r.upstream.Reference(plumbing.NewRemoteReferenceName("origin", branch), true)
+	if err != nil {
+		r.t.Fatalf("failed to find %s: %v", branch, err)
+	}
+	w, err := r.upstream.Worktree()
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	name := plumbing.NewBranchReferenceName(branch)
+	opts := &git.CheckoutOptions{Branch: name}
+	if _, err := r.upstream.Reference(name, false); err != nil {
+		opts.Hash, opts.Create = ref.Hash(), true
+	}
+	err = w.Checkout(opts)
+	if err != nil {
+		r.t.Fatalf("failed to check out %s: %v", branch, err)
+	}
+	if err := w.Reset(&git.ResetOptions{Commit: ref.Hash(), Mode: git.HardReset}); err != nil {
+		r.t.Fatalf("failed to reset %s: %v", branch, err)
+	}
+}
+
+func (r *testRemote) push(branch string) {
+	ref := plumbing.NewBranchReferenceName(branch)
+	err := r.upstream.Push(&git.PushOptions{RefSpecs: []config.RefSpec{config.RefSpec("+" + ref + ":" + ref)}})
+	if err != nil {
+		r.t.Fatalf("failed to push %s: %v", branch, err)
+	}
+}
+
+// head returns the commit a branch of the bare repository points to
+func (r *testRemote) head(branch string) string {
+	ref, err := r.bare.Reference(plumbing.NewBranchReferenceName(branch), true)
+	if err != nil {
+		return ""
+	}
+	return ref.Hash().String()
+}
+
+// cloneBump clones the remote and checks out a new bump branch
+func cloneBump(t *testing.T, b *goGitBackend) string {
+	dir, err := b.Clone(testRepo, CloneOptions{})
+	if err != nil {
+		t.Fatalf("Clone: %v", err)
+	}
+	if err := b.CreateBranch(dir, "bump", "master"); err != nil {
+		t.Fatalf("CreateBranch: %v", err)
+	}
+	return dir
+}
+
+func writeTestFile(t *testing.T, b *goGitBackend, dir, name, contents string) {
+	if err := util.WriteFile(b.fs, path.Join(dir, name), []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func headCommit(t *testing.T, b *goGitBackend, dir string) *object.Commit {
+	r, err := b.open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	head, err := r.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit, err := r.CommitObject(head.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return commit
+}
+
+func TestGoGitBackendClone(t *testing.T) {
+	b, remote := newTestBackend(t)
+
+	dir, err := b.Clone(testRepo, CloneOptions{})
+	if err != nil {
+		t.Fatalf("Clone: %v", err)
+	}
+	if head, err := b.RemoteHead(dir); err != nil || head != "master" {
+		t.Errorf("RemoteHead returned %q, %v, want master", head, err)
+	}
+	if id, err := b.GetCommitID(dir); err != nil || id != remote.head("master") {
+		t.Errorf("GetCommitID returned %q, %v, want %s", id, err, remote.head("master"))
+	}
+	readme, err := util.ReadFile(b.fs, path.Join(dir, "README.md"))
+	if err != nil || string(readme) != "# repo\n" {
+		t.Errorf("README.md contains %q, %v", readme, err)
+	}
+	if _, err := b.Clone(testRepo, CloneOptions{Filter: "blob:none"}); err == nil {
+		t.Error("cloned with a partial clone filter")
+	}
+}
+
+func TestGoGitBackendCreateBranch(t *testing.T) {
+	b, _ := newTestBackend(t)
+	dir := cloneBump(t, b)
+
+	r, err := b.open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	head, err := r.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head.Name() != plumbing.NewBranchReferenceName("bump") {
+		t.Errorf("checked out %s, want bump", head.Name())
+	}
+	if err := b.CreateBranch(dir, "other", "missing"); err == nil {
+		t.Error("created a branch from a missing base")
+	}
+}
+
+func TestGoGitBackendDiffAndPatch(t *testing.T) {
+	b, _ := newTestBackend(t)
+	dir := cloneBump(t, b)
+
+	if diff, err := b.Diff(dir); err != nil || diff != "" {
+		t.Errorf("Diff of a clean clone returned %q, %v", diff, err)
+	}
+
+	writeTestFile(t, b, dir, "README.md", "# repo\n\nBumped\n")
+	writeTestFile(t, b, dir, "VERSION", "1.2.3\n")
+	if err := b.Add(dir); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	diff, err := b.Diff(dir)
+	if err != nil {
+		t.Fatalf("Diff: %v", err)
+	}
+	for _, want := range []string{"README.md", "VERSION", "2 files changed, 3 insertions(+)"} {
+		if !strings.Contains(diff, want) {
+			t.Errorf("Diff is missing %q:\n%s", want, diff)
+		}
+	}
+
+	patch, err := b.Patch(dir)
+	if err != nil {
+		t.Fatalf("Patch: %v", err)
+	}
+	for _, want := range []string{"diff --git a/README.md b/README.md", "+Bumped", "+++ b/VERSION", "+1.2.3"} {
+		if !strings.Contains(patch, want) {
+			t.Errorf("Patch is missing %q:\n%s", want, patch)
+		}
+	}
+}
+
+func TestGoGitBackendCommit(t *testing.T) {
+	b, _ := newTestBackend(t)
+	dir := cloneBump(t, b)
+
+	writeTestFile(t, b, dir, "VERSION", "1.2.3\n")
+	if err := b.Add(dir); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	err := b.Commit(dir, "Bump version\n", CommitOptions{
+		Author: &types.CommitIdentity{Name: "Alice", Email: "alice@example.com"},
+		CoAuthors: []types.CommitIdentity{
+			{Name: "Bob", Email: "bob@example.com"},
+			// The author is not credited twice
+			{Name: "Alice", Email: "ALICE@example.com"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+
+	commit := headCommit(t, b, dir)
+	if commit.Author.Name != "Alice" || commit.Author.Email != "alice@example.com" {
+		t.Errorf("author is %s <%s>, want Alice", commit.Author.Name, commit.Author.Email)
+	}
+	if commit.Committer.Email != "bot@example.com" {
+		t.Errorf("committer is %s, want the configured author", commit.Committer.Email)
+	}
+	if want := "Bump version\n\nCo-authored-by: Bob <bob@example.com>\n"; commit.Message != want {
+		t.Errorf("message is %q, want %q", commit.Message, want)
+	}
+
+	err = b.Commit(dir, "Bump", CommitOptions{CoAuthors: []types.CommitIdentity{{Name: "Bob"}}})
+	if err == nil {
+		t.Error("committed with a co-author missing an email")
+	}
+}
+
+// commitVersion commits a VERSION file on the checked out branch
+func commitVersion(t *testing.T, b *goGitBackend, dir, version string) string {
+	writeTestFile(t, b, dir, "VERSION", version+"\n")
+	if err := b.Add(dir); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if err := b.Commit(dir, "Bump to "+version, CommitOptions{}); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	id, err := b.GetCommitID(dir)
+	if err != nil {
+		t.Fatalf("GetCommitID: %v", err)
+	}
+	return id
+}
+
+func TestGoGitBackendPush(t *testing.T) {
+	b, remote := newTestBackend(t)
+	dir := cloneBump(t, b)
+
+	ours := commitVersion(t, b, dir, "1")
+	if err := b.Push(dir, "origin", "bump", ""); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	if got := remote.head("bump"); got != ours {
+		t.Fatalf("remote bump is at %q, want %s", got, ours)
+	}
+
+	// Someone else pushes to the branch after we last fetched it
+	remote.checkout("bump")
+	theirs := remote.commit("NOTES", "notes\n", "Add notes", "carol").String()
+	remote.push("bump")
+
+	commitVersion(t, b, dir, "2")
+	if err := b.Push(dir, "origin", "bump", ours); err == nil {
+		t.Fatal("pushed over a branch that moved since it was fetched")
+	}
+	if got := remote.head("bump"); got != theirs {
+		t.Fatalf("remote bump is at %s, want their commit %s", got, theirs)
+	}
+
+	// The next run leases the head it fetched
+	dir = cloneBump(t, b)
+	head, err := b.FetchRemoteBranch(dir, "origin", "bump")
+	if err != nil || head != theirs {
+		t.Fatalf("FetchRemoteBranch returned %q, %v, want %s", head, err, theirs)
+	}
+	regenerated := commitVersion(t, b, dir, "2")
+	if err := b.Push(dir, "origin", "bump", head); err != nil {
+		t.Fatalf("Push with the fetched head: %v", err)
+	}
+	if got := remote.head("bump"); got != regenerated {
+		t.Errorf("remote bump is at %s, want %s", got, regenerated)
+	}
+}
+
+func TestGoGitBackendForeignCommits(t *testing.T) {
+	b, remote := newTestBackend(t)
+	dir := cloneBump(t, b)
+	ours := commitVersion(t, b, dir, "1")
+	if err := b.Push(dir, "origin", "bump", ""); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+
+	// A fix on top of ours, then the base branch moves on
+	remote.checkout("bump")
+	theirs := remote.commit("NOTES", "notes\n", "Add notes", "carol").String()
+	remote.push("bump")
+	remote.checkout("master")
+	remote.commit("CHANGELOG.md", "changes\n", "Update changelog", "alice")
+	remote.push("master")
+
+	if head, err := b.FetchRemoteBranch(dir, "origin", "bump"); err != nil || head != theirs {
+		t.Fatalf("FetchRemoteBranch returned %q, %v, want %s", head, err, theirs)
+	}
+	if _, err := b.FetchRemoteBranch(dir, "origin", "master"); err != nil {
+		t.Fatalf("FetchRemoteBranch: %v", err)
+	}
+	if head, err := b.FetchRemoteBranch(dir, "origin", "missing"); err != nil || head != "" {
+		t.Errorf("FetchRemoteBranch of a missing branch returned %q, %v", head, err)
+	}
+
+	foreign, err := b.ForeignCommits(dir, "master", "origin", "bump", []string{ours})
+	if err != nil {
+		t.Fatalf("ForeignCommits: %v", err)
+	}
+	if len(foreign) != 1 {
+		t.Fatalf("got foreign commits %v, want only %s", foreign, theirs)
+	}
+	if c := foreign[0]; c.SHA != theirs || c.Subject != "Add notes" || c.CommitterEmail != "carol@example.com" || c.Merge {
+		t.Errorf("got foreign commit %+v", c)
+	}
+
+	// Commits by the configured author count as ours even when unrecorded
+	foreign, err = b.ForeignCommits(dir, "master", "origin", "bump", nil)
+	if err != nil || len(foreign) != 1 {
+		t.Errorf("ForeignCommits without recorded commits returned %v, %v", foreign, err)
+	}
+
+	// Rebasing replays their commit on the new base, keeping its committer
+	if err := b.CreateBranch(dir, "rebased", "master"); err != nil {
+		t.Fatalf("CreateBranch: %v", err)
+	}
+	if err := b.CherryPick(dir, foreign); err != nil {
+		t.Fatalf("CherryPick: %v", err)
+	}
+	commit := headCommit(t, b, dir)
+	if commit.Committer.Email != "carol@example.com" || commit.Message != "Add notes" {
+		t.Errorf("picked commit by %s with message %q", commit.Committer.Email, commit.Message)
+	}
+	for _, name := range []string{"NOTES", "CHANGELOG.md"} {
+		if _, err := b.fs.Stat(path.Join(dir, name)); err != nil {
+			t.Errorf("%s is missing after the rebase: %v", name, err)
+		}
+	}
+	if _, err := b.fs.Stat(path.Join(dir, "VERSION")); err == nil {
+		t.Error("the rebase kept our commit")
+	}
+}
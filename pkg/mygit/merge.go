@@ -0,0 +1,101 @@
+package mygit
+
+import (
+	"context"
+	"fmt"
+)
+
+const (
+	MergeMethodMerge  = "merge"
+	MergeMethodSquash = "squash"
+	MergeMethodRebase = "rebase"
+)
+
+// Check states summarise the CI results of a pull request head commit
+const (
+	ChecksSuccess = "success"
+	ChecksPending = "pending"
+	ChecksFailure = "failure"
+	// ChecksNone means no CI reported anything for the commit
+	ChecksNone = "none"
+)
+
+// Review verdicts, only the latest verdict of each reviewer counts
+const (
+	reviewApproved         = "approved"
+	reviewChangesRequested = "changes_requested"
+)
+
+// MergeState is the provider independent information needed to decide
+// whether a pull request can be merged
+type MergeState struct {
+	// State is one of "open", "closed" or "merged"
+	State            string
+	HeadSHA          string
+	Checks           string
+	Approvals        int
+	ChangesRequested bool
+}
+
+// combineChecks folds individual check states into one, failures winning over pending checks
+func combineChecks(states []string) string {
+	result := ChecksNone
+	for _, state := range states {
+		switch state {
+		case ChecksFailure:
+			return ChecksFailure
+		case ChecksPending:
+			result = ChecksPending
+		case ChecksSuccess:
+			if result == ChecksNone {
+				result = ChecksSuccess
+			}
+		}
+	}
+	return result
+}
+
+// tallyReviews counts the approvals among the latest verdict of each reviewer
+func tallyReviews(verdicts map[string]string) (approvals int, changesRequested bool) {
+	for _, verdict := range verdicts {
+		switch verdict {
+		case reviewApproved:
+			approvals++
+		case reviewChangesRequested:
+			changesRequested = true
+		}
+	}
+	return approvals, changesRequested
+}
+
+// ValidateMergeMethod rejects merge methods other than merge, squash and rebase
+func ValidateMergeMethod(method string) error {
+	switch method {
+	case MergeMethodMerge, MergeMethodSquash, MergeMethodRebase:
+		return nil
+	default:
+		return fmt.Errorf("unknown merge method %q, expected one of %s, %s or %s",
+			method, MergeMethodMerge, MergeMethodSquash, MergeMethodRebase)
+	}
+}
+
+func (g *Git) MergeState(ctx context.Context, repoStr string, number int) (*MergeState, error) {
+	p, owner, repo, err := g.resolve(repoStr)
+	if err != nil {
+		return nil, err
+	}
+	return p.MergeState(ctx, owner, repo, number)
+}
+
+// MergePR merges a pull request, but only while its head is still headSHA, and
+// returns the resulting commit SHA
+func (g *Git) MergePR(ctx context.Context, repoStr string, number int, method, headSHA string) (string, error) {
+	if err := ValidateMergeMethod(method); err != nil {
+		return "", err
+	}
+	p, owner, repo, err := g.resolve(repoStr)
+	if err != nil {
+		return "", err
+	}
+	return p.MergePR(ctx, owner, repo, number, method, headSHA)
+}
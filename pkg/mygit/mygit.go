@@ -3,50 +3,205 @@ package mygit
 import (
 	"context"
 	"fmt"
+	"net/url"
 	"os"
 	"os/exec"
 	"strings"
+	"time"
 
+	"github.com/nsxbet/proliferate/pkg/redact"
 	"github.com/nsxbet/proliferate/pkg/types"
-
-	"github.com/google/go-github/github"
-	"golang.org/x/oauth2"
 )
 
 type Git struct {
-	config types.Config
-	gh     *github.Client
+	config     types.Config
+	providers  map[string]Provider
+	cloneHosts map[string]string
+	// cache is nil when the clone cache is disabled
+	cache   *mirrorCache
+	backend Backend
+}
+
+// NewGit sets up github.com with the configured GitHub token or app plus one
+// provider per entry in the config providers list, keyed by host
+func NewGit(cfg types.Config) (*Git, error) {
+	providerConfigs := append([]types.ProviderConfig{{
+		Host:  defaultHost,
+		Type:  ProviderGitHub,
+		Token: cfg.GetGithubToken(),
+		App:   cfg.GetGithubApp(),
+	}}, cfg.GetProviders()...)
+
+	providers := make(map[string]Provider)
+	cloneHosts := make(map[string]string)
+	for _, pc := range providerConfigs {
+		// The host can be derived from the base URL of self-hosted instances
+		if pc.Host == "" && pc.BaseURL != "" {
+			if u, err := url.Parse(pc.BaseURL); err == nil {
+				pc.Host = u.Host
+			}
+		}
+
+		p, err := newProvider(pc)
+		if err != nil {
+			return nil, err
+		}
+		providers[pc.Host] = p
+		cloneHosts[pc.Host] = pc.CloneHost
+	}
+
+	cache, err := newMirrorCache(cfg.GetCache())
+	if err != nil {
+		return nil, err
+	}
+
+	g := &Git{
+		config:     cfg,
+		providers:  providers,
+		cloneHosts: cloneHosts,
+		cache:      cache,
+	}
+	if g.backend, err = newBackend(g, cfg.GetGitBackend()); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// credentialHelper answers git credential requests from the environment of the
+// git process, so tokens never end up in .git/config, remote URLs or git output
+const credentialHelper = `!f() { test "$1" = get && echo "username=${PRO_GIT_USERNAME}" && echo "password=${PRO_GIT_PASSWORD}"; }; f`
+
+// repoConfigKey stores the "host/owner/repo" string of a clone in its local git config
+const repoConfigKey = "proliferate.repo"
+
+// remoteRepoConfigKey stores the "host/owner/repo" string of any other remote
+const remoteRepoConfigKey = "remote.%s.proliferateRepo"
+
+// authenticatedCommand builds a git command that authenticates against the provider of repo
+func (g *execBackend) authenticatedCommand(repo string, args ...string) (*exec.Cmd, error) {
+	host, owner, _, err := g.ParseRepoString(repo)
+	if err != nil {
+		return nil, err
+	}
+	p, err := g.providerFor(host)
+	if err != nil {
+		return nil, err
+	}
+
+	username, password, err := p.Credentials(context.Background(), owner)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get credentials for %s: %v", repo, err)
+	}
+	redact.Register(password)
+
+	// The empty helper resets any helper inherited from the user git config
+	gitArgs := append([]string{"-c", "credential.helper=", "-c", "credential.helper=" + credentialHelper}, args...)
+	cmd := exec.Command("git", gitArgs...)
+	cmd.Env = append(os.Environ(),
+		"PRO_GIT_USERNAME="+username,
+		"PRO_GIT_PASSWORD="+password,
+		"GIT_TERMINAL_PROMPT=0",
+	)
+	return cmd, nil
+}
+
+// clonedRepo returns the repository string a directory was cloned from
+func (g *execBackend) clonedRepo(dir string) (string, error) {
+	cmd := exec.Command("git", "-C", dir, "config", "--local", repoConfigKey)
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to read cloned repository of %s: %v", dir, err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// remoteRepo returns the repository string behind a remote of a clone
+func (g *execBackend) remoteRepo(dir, remote string) (string, error) {
+	if remote == "origin" {
+		return g.clonedRepo(dir)
+	}
+
+	cmd := exec.Command("git", "-C", dir, "config", "--local", fmt.Sprintf(remoteRepoConfigKey, remote))
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to read repository of remote %s: %v", remote, err)
+	}
+	return strings.TrimSpace(string(output)), nil
 }
 
-func NewGit(cfg types.Config) *Git {
-	ctx := context.Background()
-	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: cfg.GetGithubToken()})
-	tc := oauth2.NewClient(ctx, ts)
-	gh := github.NewClient(tc)
+// AddRemote adds repo as a named remote of the clone in dir
+func (g *execBackend) AddRemote(dir string, name string, repo string) error {
+	remoteURL, err := g.cloneURL(repo)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command("git", "-C", dir, "remote", "add", name, remoteURL)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to add remote %s: %s: %v", name, output, err)
+	}
 
-	return &Git{
-		config: cfg,
-		gh:     gh,
+	configCmd := exec.Command("git", "-C", dir, "config", "--local", fmt.Sprintf(remoteRepoConfigKey, name), repo)
+	if output, err := configCmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to record repository of remote %s: %s: %v", name, output, err)
 	}
+	return nil
 }
 
-func (g *Git) Clone(repo string) (string, error) {
+func (g *execBackend) Clone(repo string, opts CloneOptions) (string, error) {
 	tmpDir, err := os.MkdirTemp("", "proliferate-*")
 	if err != nil {
 		return "", fmt.Errorf("failed to create temp directory: %v", err)
 	}
 
-	cloneURL := fmt.Sprintf("https://oauth2:%s@%s.git", g.config.GetGithubToken(), repo)
-	cmd := exec.Command("git", "clone", cloneURL, tmpDir)
+	cloneURL, err := g.cloneURL(repo)
+	if err != nil {
+		os.RemoveAll(tmpDir)
+		return "", err
+	}
+
+	// With the cache, the clone copies the objects of the mirror and only
+	// fetches what the mirror lacks. It dissociates from the mirror, so pruning
+	// the cache never breaks a work tree.
+	args := append([]string{"clone"}, opts.cloneArgs()...)
+	if g.cache != nil {
+		mirror, unlock, err := g.updateMirror(repo, cloneURL)
+		if err != nil {
+			os.RemoveAll(tmpDir)
+			return "", err
+		}
+		defer unlock()
+		args = append(args, "--reference", mirror, "--dissociate")
+	}
+
+	args = append(args, cloneURL, tmpDir)
+	cmd, err := g.authenticatedCommand(repo, args...)
+	if err != nil {
+		os.RemoveAll(tmpDir)
+		return "", err
+	}
 	if output, err := cmd.CombinedOutput(); err != nil {
 		os.RemoveAll(tmpDir)
-		return "", fmt.Errorf("failed to clone repository: %s: %v", output, err)
+		return "", fmt.Errorf("failed to clone repository: %s: %v", redact.Bytes(output), err)
+	}
+
+	configCmd := exec.Command("git", "-C", tmpDir, "config", "--local", repoConfigKey, repo)
+	if output, err := configCmd.CombinedOutput(); err != nil {
+		os.RemoveAll(tmpDir)
+		return "", fmt.Errorf("failed to record cloned repository: %s: %v", output, err)
+	}
+
+	if len(opts.SparsePaths) > 0 {
+		if err := g.setSparsePaths(tmpDir, opts.SparsePaths); err != nil {
+			os.RemoveAll(tmpDir)
+			return "", err
+		}
 	}
 
 	return tmpDir, nil
 }
 
-func (g *Git) Diff(dir string) (string, error) {
+func (g *execBackend) Diff(dir string) (string, error) {
 	configCmd := exec.Command("git", "-C", dir, "config", "--local", "diff.noprefix", "true")
 	if err := configCmd.Run(); err != nil {
 		return "", fmt.Errorf("failed to configure git diff: %v", err)
@@ -57,10 +212,34 @@ func (g *Git) Diff(dir string) (string, error) {
 		return "", fmt.Errorf("failed to stage changes: %v", err)
 	}
 
-	cmd := exec.Command("git", "-C", dir, "diff", "--cached", "--stat")
-	output, err := cmd.CombinedOutput()
+	// Partial clones fetch the blobs the diff needs, keep that off stdout
+	cmd, err := g.checkoutCommand(dir, "diff", "--cached", "--stat")
+	if err != nil {
+		return "", err
+	}
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to get git diff: %s%v", stderr(err), err)
+	}
+
+	diffOutput := strings.TrimSpace(string(output))
+	if diffOutput == "" {
+		return "", nil
+	}
+
+	lines := strings.Split(diffOutput, "\n")
+	return strings.Join(lines, "\n "), nil
+}
+
+// DiffRemoteBranch renders the diff stat between a remote branch and the changes staged by Diff
+func (g *execBackend) DiffRemoteBranch(dir string, remote string, branch string) (string, error) {
+	cmd, err := g.checkoutCommand(dir, "diff", "--cached", "--stat", fmt.Sprintf("refs/remotes/%s/%s", remote, branch))
+	if err != nil {
+		return "", err
+	}
+	output, err := cmd.Output()
 	if err != nil {
-		return "", fmt.Errorf("failed to get git diff: %v", err)
+		return "", fmt.Errorf("failed to diff against %s/%s: %s%v", remote, branch, stderr(err), err)
 	}
 
 	diffOutput := strings.TrimSpace(string(output))
@@ -72,7 +251,21 @@ func (g *Git) Diff(dir string) (string, error) {
 	return strings.Join(lines, "\n "), nil
 }
 
-func (g *Git) Add(dir string) error {
+// Patch returns the changes staged by Diff as a unified diff
+func (g *execBackend) Patch(dir string) (string, error) {
+	// Override diff.noprefix so the patch applies with git apply
+	cmd, err := g.checkoutCommand(dir, "diff", "--cached", "--no-color", "--no-ext-diff", "--src-prefix=a/", "--dst-prefix=b/")
+	if err != nil {
+		return "", err
+	}
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to get git patch: %s%v", stderr(err), err)
+	}
+	return string(output), nil
+}
+
+func (g *execBackend) Add(dir string) error {
 	cmd := exec.Command("git", "-C", dir, "add", ".")
 	if output, err := cmd.CombinedOutput(); err != nil {
 		return fmt.Errorf("failed to add changes: %s: %v", output, err)
@@ -80,7 +273,14 @@ func (g *Git) Add(dir string) error {
 	return nil
 }
 
-func (g *Git) Commit(dir string, message string) error {
+// CommitOptions credits a commit to people other than the configured author,
+// who always remains the committer
+type CommitOptions struct {
+	Author    *types.CommitIdentity
+	CoAuthors []types.CommitIdentity
+}
+
+func (g *execBackend) Commit(dir string, message string, opts CommitOptions) error {
 	// Set the local git config for this repository
 	emailCmd := exec.Command("git", "-C", dir, "config", "user.email", g.config.GetAuthorEmail())
 	if output, err := emailCmd.CombinedOutput(); err != nil {
@@ -92,115 +292,279 @@ func (g *Git) Commit(dir string, message string) error {
 		return fmt.Errorf("failed to set git name: %s: %v", output, err)
 	}
 
+	if err := g.configureSigning(dir); err != nil {
+		return err
+	}
+
+	args := []string{"-C", dir, "commit", "-m", message}
+	if opts.Author != nil {
+		if opts.Author.Name == "" || opts.Author.Email == "" {
+			return fmt.Errorf("commitAuthor needs both a name and an email")
+		}
+		args = append(args, "--author", opts.Author.String())
+	}
+	trailers, err := coAuthorTrailers(opts)
+	if err != nil {
+		return err
+	}
+	if trailers != "" {
+		args = append(args, "-m", trailers)
+	}
+
 	// Perform the commit
-	cmd := exec.Command("git", "-C", dir, "commit", "-m", message)
+	cmd := exec.Command("git", args...)
 	if output, err := cmd.CombinedOutput(); err != nil {
 		return fmt.Errorf("failed to commit changes: %s: %v", output, err)
 	}
 	return nil
 }
 
-func (g *Git) CreateBranch(dir string, branch string) error {
-	cmd := exec.Command("git", "-C", dir, "checkout", "-b", branch)
+// coAuthorTrailers builds the Co-authored-by trailers, skipping empty entries
+// left by templates, duplicates and the author itself
+func coAuthorTrailers(opts CommitOptions) (string, error) {
+	seen := make(map[string]bool)
+	if opts.Author != nil {
+		seen[strings.ToLower(opts.Author.Email)] = true
+	}
+
+	var trailers []string
+	for _, coAuthor := range opts.CoAuthors {
+		if coAuthor.Name == "" && coAuthor.Email == "" {
+			continue
+		}
+		if coAuthor.Name == "" || coAuthor.Email == "" {
+			return "", fmt.Errorf("co-author %q needs both a name and an email", coAuthor.String())
+		}
+		email := strings.ToLower(coAuthor.Email)
+		if seen[email] {
+			continue
+		}
+		seen[email] = true
+		trailers = append(trailers, "Co-authored-by: "+coAuthor.String())
+	}
+	return strings.Join(trailers, "\n"), nil
+}
+
+// ResolveBaseBranch returns base when set, otherwise the repository default
+// branch from the provider API, falling back to the origin/HEAD of the clone
+func (g *Git) ResolveBaseBranch(ctx context.Context, repo, dir, base string) (string, error) {
+	if base != "" {
+		return base, nil
+	}
+
+	branch, apiErr := g.DefaultBranch(ctx, repo)
+	if apiErr == nil && branch != "" {
+		return branch, nil
+	}
+	if apiErr == nil {
+		apiErr = fmt.Errorf("the API returned no default branch")
+	}
+
+	branch, cloneErr := g.backend.RemoteHead(dir)
+	if cloneErr == nil && branch == "" {
+		cloneErr = fmt.Errorf("the clone has no origin/HEAD")
+	}
+	if cloneErr != nil {
+		return "", fmt.Errorf("failed to resolve default branch: %v; %v", apiErr, cloneErr)
+	}
+	return branch, nil
+}
+
+// RemoteHead returns the default branch origin advertised when dir was cloned
+func (g *execBackend) RemoteHead(dir string) (string, error) {
+	cmd := exec.Command("git", "-C", dir, "symbolic-ref", "--short", "refs/remotes/origin/HEAD")
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to read origin/HEAD: %v", err)
+	}
+	return strings.TrimPrefix(strings.TrimSpace(string(output)), "origin/"), nil
+}
+
+// CreateBranch creates branch starting from the remote base branch
+func (g *execBackend) CreateBranch(dir string, branch string, base string) error {
+	cmd, err := g.checkoutCommand(dir, "checkout", "--no-track", "-b", branch, "origin/"+base)
+	if err != nil {
+		return err
+	}
 	if output, err := cmd.CombinedOutput(); err != nil {
 		return fmt.Errorf("failed to create branch: %s: %v", output, err)
 	}
 	return nil
 }
 
-func (g *Git) Push(dir string, branch string) error {
-	cmd := exec.Command("git", "-C", dir, "push", "--force", "origin", branch)
+// FetchRemoteBranch fetches branch from remote and returns its head commit,
+// or an empty string when the branch does not exist on the remote
+func (g *execBackend) FetchRemoteBranch(dir string, remote string, branch string) (string, error) {
+	repo, err := g.remoteRepo(dir, remote)
+	if err != nil {
+		return "", err
+	}
+
+	ref := "refs/heads/" + branch
+	cmd, err := g.authenticatedCommand(repo, "-C", dir, "ls-remote", "--heads", remote, ref)
+	if err != nil {
+		return "", err
+	}
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("failed to list remote branch: %s: %v", redact.Bytes(output), err)
+	}
+	fields := strings.Fields(string(output))
+	if len(fields) == 0 {
+		return "", nil
+	}
+
+	args := []string{"-C", dir, "fetch", remote, fmt.Sprintf("+%s:refs/remotes/%s/%s", ref, remote, branch)}
+	// Keep shallow clones shallow, ForeignCommits deepens them when needed
+	shallow, err := isShallow(dir)
+	if err != nil {
+		return "", err
+	}
+	if shallow {
+		args = append(args, fmt.Sprintf("--depth=%d", shallowFetchDepth))
+	}
+	cmd, err = g.authenticatedCommand(repo, args...)
+	if err != nil {
+		return "", err
+	}
 	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("failed to push branch: %s: %v", output, err)
+		return "", fmt.Errorf("failed to fetch remote branch: %s: %v", redact.Bytes(output), err)
 	}
-	return nil
+	return fields[0], nil
 }
 
-func (g *Git) GetCommitID(dir string) (string, error) {
-	cmd := exec.Command("git", "-C", dir, "rev-parse", "HEAD")
-	output, err := cmd.Output()
+// ForeignCommits lists the commits on the remote branch, on top of base, that
+// proliferate did not make, oldest first. A commit is ours when it is one of
+// ours or was committed with the configured author email, whoever it is
+// authored by.
+func (g *execBackend) ForeignCommits(dir string, base string, remote string, branch string, ours []string) ([]BranchCommit, error) {
+	shallow, err := isShallow(dir)
 	if err != nil {
-		return "", fmt.Errorf("failed to get commit ID: %v", err)
+		return nil, err
+	}
+	if shallow {
+		if err := g.deepenToMergeBase(dir, base, remote, branch); err != nil {
+			return nil, err
+		}
 	}
-	return strings.TrimSpace(string(output)), nil
-}
 
-func (g *Git) CreatePR(ctx context.Context, owner, repo, branch, base, title, body string, labels []string, assignees []string) (*github.PullRequest, error) {
-	existingPRs, _, err := g.gh.PullRequests.List(ctx, owner, repo, &github.PullRequestListOptions{
-		Head: fmt.Sprintf("%s:%s", owner, branch),
-		Base: base,
-	})
+	cmd := exec.Command("git", "-C", dir, "log", "--reverse", "--topo-order", "--format=%H%x09%P%x09%cn%x09%ce%x09%cI%x09%s",
+		fmt.Sprintf("origin/%s..%s/%s", base, remote, branch))
+	output, err := cmd.CombinedOutput()
 	if err != nil {
-		return nil, fmt.Errorf("failed to list PRs: %v", err)
+		return nil, fmt.Errorf("failed to list branch commits: %s: %v", output, err)
 	}
 
-	var pr *github.PullRequest
-	if len(existingPRs) > 0 {
-		var matchingPR *github.PullRequest
-		for _, existingPR := range existingPRs {
-			if existingPR.GetHead().GetRef() == branch {
-				matchingPR = existingPR
-				break
-			}
-		}
+	known := make(map[string]bool)
+	for _, sha := range ours {
+		known[sha] = true
+	}
+	authorEmail := g.config.GetAuthorEmail()
 
-		if matchingPR != nil {
-			pr, _, err = g.gh.PullRequests.Edit(ctx, owner, repo, matchingPR.GetNumber(), &github.PullRequest{
-				Title: github.String(title),
-				Body:  github.String(body),
-			})
-			if err != nil {
-				return nil, fmt.Errorf("failed to update PR: %v", err)
-			}
-		} else {
-			newPR := &github.NewPullRequest{
-				Title:               github.String(title),
-				Head:                github.String(branch),
-				Base:                github.String(base),
-				Body:                github.String(body),
-				MaintainerCanModify: github.Bool(true),
-			}
-			pr, _, err = g.gh.PullRequests.Create(ctx, owner, repo, newPR)
-			if err != nil {
-				return nil, fmt.Errorf("failed to create PR: %v", err)
-			}
+	var foreign []BranchCommit
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		parts := strings.SplitN(line, "\t", 6)
+		if len(parts) != 6 {
+			continue
 		}
-	} else {
-		newPR := &github.NewPullRequest{
-			Title:               github.String(title),
-			Head:                github.String(branch),
-			Base:                github.String(base),
-			Body:                github.String(body),
-			MaintainerCanModify: github.Bool(true),
+		commit := BranchCommit{
+			SHA:            parts[0],
+			Merge:          len(strings.Fields(parts[1])) > 1,
+			CommitterName:  parts[2],
+			CommitterEmail: parts[3],
+			Subject:        parts[5],
 		}
-		pr, _, err = g.gh.PullRequests.Create(ctx, owner, repo, newPR)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create PR: %v", err)
+		if known[commit.SHA] || (authorEmail != "" && commit.CommitterEmail == authorEmail) {
+			continue
+		}
+		if commit.CommitterDate, err = time.Parse(time.RFC3339, parts[4]); err != nil {
+			return nil, fmt.Errorf("failed to read the date of commit %s: %v", commit.SHA, err)
 		}
+		foreign = append(foreign, commit)
 	}
+	return foreign, nil
+}
 
-	_, _, err = g.gh.Issues.ReplaceLabelsForIssue(ctx, owner, repo, pr.GetNumber(), labels)
-	if err != nil {
-		return nil, fmt.Errorf("failed to update labels: %v", err)
+// CherryPick replays commits on the checked out branch in order. They keep
+// their committer, so that they are still told apart from ours afterwards.
+func (g *execBackend) CherryPick(dir string, commits []BranchCommit) error {
+	// The replayed commits are new commits, signed like ours
+	if err := g.configureSigning(dir); err != nil {
+		return err
 	}
 
-	if len(pr.Assignees) > 0 {
-		var currentAssignees []string
-		for _, a := range pr.Assignees {
-			currentAssignees = append(currentAssignees, a.GetLogin())
+	for _, commit := range commits {
+		if commit.Merge {
+			return fmt.Errorf("cannot rebase merge commit %s", commit)
 		}
-		_, _, err = g.gh.Issues.RemoveAssignees(ctx, owner, repo, pr.GetNumber(), currentAssignees)
+
+		cmd, err := g.checkoutCommand(dir, "cherry-pick", "--allow-empty", "--keep-redundant-commits", commit.SHA)
 		if err != nil {
-			return nil, fmt.Errorf("failed to remove existing assignees: %v", err)
+			return err
+		}
+		cmd.Env = append(cmd.Env,
+			"GIT_COMMITTER_NAME="+commit.CommitterName,
+			"GIT_COMMITTER_EMAIL="+commit.CommitterEmail,
+			"GIT_COMMITTER_DATE="+commit.CommitterDate.Format(time.RFC3339),
+		)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			abort := exec.Command("git", "-C", dir, "cherry-pick", "--abort")
+			abort.Run()
+			return fmt.Errorf("failed to rebase commit %s: %s: %v", commit, redact.Bytes(output), err)
 		}
 	}
+	return nil
+}
 
-	if len(assignees) > 0 {
-		_, _, err = g.gh.Issues.AddAssignees(ctx, owner, repo, pr.GetNumber(), assignees)
-		if err != nil {
-			return nil, fmt.Errorf("failed to add assignees: %v", err)
+// Push pushes branch to remote. When expectedHead is set the remote branch is
+// overwritten, but only if it still points at expectedHead.
+func (g *execBackend) Push(dir string, remote string, branch string, expectedHead string) error {
+	repo, err := g.remoteRepo(dir, remote)
+	if err != nil {
+		return err
+	}
+
+	args := []string{"-C", dir, "push", remote, branch}
+	if expectedHead != "" {
+		args = append(args, fmt.Sprintf("--force-with-lease=refs/heads/%s:%s", branch, expectedHead))
+	}
+
+	cmd, err := g.authenticatedCommand(repo, args...)
+	if err != nil {
+		return err
+	}
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to push branch: %s: %v", redact.Bytes(output), err)
+	}
+	return nil
+}
+
+// DeleteRemoteBranch deletes branch from the remote of repo without cloning it.
+// A branch that is already gone is not an error.
+func (g *execBackend) DeleteRemoteBranch(repo string, branch string) error {
+	cloneURL, err := g.cloneURL(repo)
+	if err != nil {
+		return err
+	}
+
+	cmd, err := g.authenticatedCommand(repo, "push", cloneURL, "--delete", "refs/heads/"+branch)
+	if err != nil {
+		return err
+	}
+	if output, err := cmd.CombinedOutput(); err != nil {
+		if strings.Contains(string(output), "remote ref does not exist") {
+			return nil
 		}
+		return fmt.Errorf("failed to delete remote branch: %s: %v", redact.Bytes(output), err)
 	}
+	return nil
+}
 
-	return pr, nil
+func (g *execBackend) GetCommitID(dir string) (string, error) {
+	cmd := exec.Command("git", "-C", dir, "rev-parse", "HEAD")
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to get commit ID: %v", err)
+	}
+	return strings.TrimSpace(string(output)), nil
 }
@@ -0,0 +1,202 @@
+package mygit
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/nsxbet/proliferate/pkg/types"
+)
+
+const (
+	ProviderGitHub = "github"
+	ProviderGitLab = "gitlab"
+	ProviderGitea  = "gitea"
+
+	ProviderBitbucket       = "bitbucket"
+	ProviderBitbucketServer = "bitbucket-server"
+)
+
+const defaultHost = "github.com"
+
+// Provider is a code hosting service proliferate can open pull requests on
+type Provider interface {
+	CreatePR(ctx context.Context, owner, repo string, opts PROptions) (*PullRequest, error)
+	GetPRStatus(ctx context.Context, owner, repo string, number int) (string, error)
+	// ClosePR closes a pull request without merging it, first leaving comment when it is not empty
+	ClosePR(ctx context.Context, owner, repo string, number int, comment string) error
+	MergeState(ctx context.Context, owner, repo string, number int) (*MergeState, error)
+	MergePR(ctx context.Context, owner, repo string, number int, method, headSHA string) (string, error)
+	// MarkReady turns a draft pull request into one that is ready for review
+	MarkReady(ctx context.Context, owner, repo string, number int) error
+	FilterRepositoriesByOrg(ctx context.Context, org, pattern string) ([]string, error)
+	DefaultBranch(ctx context.Context, owner, repo string) (string, error)
+	CloneURL(owner, repo string) string
+	Credentials(ctx context.Context, owner string) (username, password string, err error)
+}
+
+// PROptions holds the provider independent fields of a pull request
+type PROptions struct {
+	Branch    string
+	Base      string
+	Title     string
+	Body      string
+	Labels    []string
+	Assignees []string
+	// Reviewers and TeamReviewers that cannot be requested end up as warnings
+	Reviewers     []string
+	TeamReviewers []string
+	// Draft only applies to new pull requests, existing ones keep their draft state
+	Draft bool
+	// HeadOwner owns the fork holding Branch for cross-repository pull requests
+	HeadOwner string
+}
+
+// PullRequest is the provider independent view of a pull request.
+// State is one of "open", "closed" or "merged".
+type PullRequest struct {
+	Number int
+	URL    string
+	State  string
+	Draft  bool
+	// Warnings lists the parts of the request that could not be applied
+	Warnings []string
+}
+
+func newProvider(cfg types.ProviderConfig) (Provider, error) {
+	if cfg.Host == "" {
+		return nil, fmt.Errorf("provider of type %q is missing a host", cfg.Type)
+	}
+
+	switch cfg.Type {
+	case ProviderGitHub:
+		return newGitHubProvider(cfg)
+	case ProviderGitLab:
+		return newGitLabProvider(cfg), nil
+	case ProviderGitea, "forgejo":
+		return newGiteaProvider(cfg), nil
+	case ProviderBitbucket:
+		return newBitbucketCloudProvider(cfg), nil
+	case ProviderBitbucketServer:
+		return newBitbucketServerProvider(cfg), nil
+	default:
+		return nil, fmt.Errorf("unknown provider type %q for host %s", cfg.Type, cfg.Host)
+	}
+}
+
+func (g *Git) providerFor(host string) (Provider, error) {
+	p, ok := g.providers[host]
+	if !ok {
+		return nil, fmt.Errorf("no provider configured for host %s", host)
+	}
+	return p, nil
+}
+
+// resolve finds the provider responsible for a "host/owner/repo" string
+func (g *Git) resolve(repoStr string) (Provider, string, string, error) {
+	host, owner, repo, err := g.ParseRepoString(repoStr)
+	if err != nil {
+		return nil, "", "", err
+	}
+	p, err := g.providerFor(host)
+	if err != nil {
+		return nil, "", "", err
+	}
+	return p, owner, repo, nil
+}
+
+// ParseRepoString splits "host/owner/repo" into its parts. The owner may
+// contain slashes for providers with nested groups, like GitLab.
+func (g *Git) ParseRepoString(repoStr string) (host string, owner string, repo string, err error) {
+	parts := strings.Split(strings.TrimSuffix(repoStr, ".git"), "/")
+	if len(parts) < 3 {
+		return "", "", "", fmt.Errorf("invalid repo format, expected 'host/owner/repo'")
+	}
+	for _, part := range parts {
+		if part == "" {
+			return "", "", "", fmt.Errorf("invalid repo format %q, expected 'host/owner/repo'", repoStr)
+		}
+	}
+	return parts[0], strings.Join(parts[1:len(parts)-1], "/"), parts[len(parts)-1], nil
+}
+
+// NormalizeRepo turns the "owner/repo" shorthand used in config.yaml into a
+// "github.com/owner/repo" repository string, leaving full strings untouched
+func NormalizeRepo(repoStr string) string {
+	repoStr = strings.TrimSuffix(strings.TrimSpace(repoStr), ".git")
+	if strings.Count(repoStr, "/") == 1 {
+		return defaultHost + "/" + repoStr
+	}
+	return repoStr
+}
+
+func (g *Git) CreatePR(ctx context.Context, repoStr string, opts PROptions) (*PullRequest, error) {
+	p, owner, repo, err := g.resolve(repoStr)
+	if err != nil {
+		return nil, err
+	}
+	return p.CreatePR(ctx, owner, repo, opts)
+}
+
+func (g *Git) GetPRStatus(ctx context.Context, repoStr string, number int) (string, error) {
+	p, owner, repo, err := g.resolve(repoStr)
+	if err != nil {
+		return "", err
+	}
+	return p.GetPRStatus(ctx, owner, repo, number)
+}
+
+func (g *Git) ClosePR(ctx context.Context, repoStr string, number int, comment string) error {
+	p, owner, repo, err := g.resolve(repoStr)
+	if err != nil {
+		return err
+	}
+	return p.ClosePR(ctx, owner, repo, number, comment)
+}
+
+func (g *Git) DefaultBranch(ctx context.Context, repoStr string) (string, error) {
+	p, owner, repo, err := g.resolve(repoStr)
+	if err != nil {
+		return "", err
+	}
+	return p.DefaultBranch(ctx, owner, repo)
+}
+
+// FilterRepositoriesByOrg lists the repositories of an organization matching a regex
+// pattern. The organization may be prefixed with a host, e.g. "gitlab.com/my-group".
+func (g *Git) FilterRepositoriesByOrg(ctx context.Context, org, pattern string) ([]string, error) {
+	host := defaultHost
+	if parts := strings.SplitN(org, "/", 2); len(parts) == 2 {
+		if _, known := g.providers[parts[0]]; known || strings.Contains(parts[0], ".") {
+			host, org = parts[0], parts[1]
+		}
+	}
+
+	p, err := g.providerFor(host)
+	if err != nil {
+		return nil, err
+	}
+	return p.FilterRepositoriesByOrg(ctx, org, pattern)
+}
+
+// cloneURL returns the clone URL of a repository, without credentials
+func (g *Git) cloneURL(repoStr string) (string, error) {
+	host, owner, repo, err := g.ParseRepoString(repoStr)
+	if err != nil {
+		return "", err
+	}
+	p, err := g.providerFor(host)
+	if err != nil {
+		return "", err
+	}
+
+	u, err := url.Parse(p.CloneURL(owner, repo))
+	if err != nil {
+		return "", fmt.Errorf("invalid clone URL: %v", err)
+	}
+	if cloneHost := g.cloneHosts[host]; cloneHost != "" {
+		u.Host = cloneHost
+	}
+	return u.String(), nil
+}
@@ -0,0 +1,80 @@
+package mygit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// restClient is a minimal JSON client shared by the providers that have no
+// dedicated Go SDK in this module
+type restClient struct {
+	baseURL string
+	header  http.Header
+	http    *http.Client
+}
+
+func newRestClient(baseURL string, header http.Header) *restClient {
+	return &restClient{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		header:  header,
+		http:    http.DefaultClient,
+	}
+}
+
+// do sends a request and decodes the JSON response into out when it is not nil.
+// Any non 2xx response is returned as an error including the response body.
+func (c *restClient) do(ctx context.Context, method, path string, query url.Values, body, out interface{}) (*http.Response, error) {
+	endpoint := c.baseURL + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode request: %v", err)
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range c.header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp, fmt.Errorf("failed to read response: %v", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp, fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(data)))
+	}
+
+	if out != nil && len(data) > 0 {
+		if err := json.Unmarshal(data, out); err != nil {
+			return resp, fmt.Errorf("failed to decode response: %v", err)
+		}
+	}
+	return resp, nil
+}
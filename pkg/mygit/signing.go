@@ -0,0 +1,80 @@
+package mygit
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+
+	"github.com/nsxbet/proliferate/pkg/types"
+)
+
+// CommitVerification is the signature verification of a commit as seen by the host
+type CommitVerification struct {
+	Verified bool
+	Reason   string
+}
+
+// CommitVerifier is implemented by providers that verify commit signatures
+type CommitVerifier interface {
+	CommitVerification(ctx context.Context, owner, repo, sha string) (*CommitVerification, error)
+}
+
+// SigningEnabled reports whether commits are signed
+func (g *Git) SigningEnabled() bool {
+	return g.config.GetSigning() != nil
+}
+
+// configureSigning makes every commit in dir signed with the configured key
+func (g *execBackend) configureSigning(dir string) error {
+	signing := g.config.GetSigning()
+	if signing == nil {
+		return nil
+	}
+	if signing.Key == "" {
+		return fmt.Errorf("signing.key is required to sign commits")
+	}
+
+	settings := [][2]string{
+		{"user.signingkey", signing.Key},
+		{"commit.gpgsign", "true"},
+	}
+	switch signing.Format {
+	case types.SigningFormatGPG, "":
+		settings = append(settings, [2]string{"gpg.format", "openpgp"})
+		if signing.Program != "" {
+			settings = append(settings, [2]string{"gpg.program", signing.Program})
+		}
+	case types.SigningFormatSSH:
+		settings = append(settings, [2]string{"gpg.format", "ssh"})
+		if signing.Program != "" {
+			settings = append(settings, [2]string{"gpg.ssh.program", signing.Program})
+		}
+	default:
+		return fmt.Errorf("unknown signing format %q, expected %s or %s", signing.Format, types.SigningFormatGPG, types.SigningFormatSSH)
+	}
+
+	for _, setting := range settings {
+		cmd := exec.Command("git", "-C", dir, "config", setting[0], setting[1])
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to set %s: %s: %v", setting[0], output, err)
+		}
+	}
+	return nil
+}
+
+// CommitVerification asks the host of repoStr whether the signature of sha is verified
+func (g *Git) CommitVerification(ctx context.Context, repoStr string, sha string) (*CommitVerification, error) {
+	host, owner, repo, err := g.ParseRepoString(repoStr)
+	if err != nil {
+		return nil, err
+	}
+	p, err := g.providerFor(host)
+	if err != nil {
+		return nil, err
+	}
+	v, ok := p.(CommitVerifier)
+	if !ok {
+		return nil, fmt.Errorf("commit verification is not supported by the provider of %s", host)
+	}
+	return v.CommitVerification(ctx, owner, repo, sha)
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/charmbracelet/lipgloss"
+	"github.com/nsxbet/proliferate/pkg/redact"
 	"github.com/nsxbet/proliferate/pkg/types"
 	"gopkg.in/yaml.v3"
 )
@@ -22,9 +23,20 @@ var (
 			MarginBottom(1)
 
 	stateStyles = map[string]lipgloss.Style{
-		"open":   lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#00FF9F")),
-		"closed": lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#FF6B8B")),
-		"merged": lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#A682FF")),
+		"open":       lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#00FF9F")),
+		"closed":     lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#FF6B8B")),
+		"merged":     lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#A682FF")),
+		"skipped":    lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#FFB86C")),
+		"pending":    lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#989898")),
+		"no-changes": lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#6C9BFF")),
+	}
+
+	scriptOutcomeStyles = map[string]lipgloss.Style{
+		types.ScriptOutcomeSucceeded: lipgloss.NewStyle().Foreground(lipgloss.Color("#00FF9F")),
+		types.ScriptOutcomeFailed:    lipgloss.NewStyle().Foreground(lipgloss.Color("#FF6B8B")),
+		types.ScriptOutcomeIgnored:   lipgloss.NewStyle().Foreground(lipgloss.Color("#989898")),
+		types.ScriptOutcomeContinued: lipgloss.NewStyle().Foreground(lipgloss.Color("#FFB86C")),
+		types.ScriptOutcomeSkipped:   lipgloss.NewStyle().Foreground(lipgloss.Color("#FFB86C")),
 	}
 
 	treeStyle = lipgloss.NewStyle().
@@ -103,9 +115,12 @@ func (p *ConsolePrinter) PrintNamespaceHeader(namespace string) {
 func (p *ConsolePrinter) PrintPRStatus(name string, pr types.PRStatus, state string) {
 	stateStyle := stateStyles[state]
 	stateEmoji := map[string]string{
-		"open":   "🟢",
-		"closed": "🔴",
-		"merged": "🟣",
+		"open":       "🟢",
+		"closed":     "🔴",
+		"merged":     "🟣",
+		"skipped":    "🟠",
+		"pending":    "⚪",
+		"no-changes": "🔵",
 	}[state]
 
 	// Build the tree structure
@@ -113,26 +128,130 @@ func (p *ConsolePrinter) PrintPRStatus(name string, pr types.PRStatus, state str
 		titleStyle.Render(name),
 		fmt.Sprintf("├── Repository: %s", pr.Repository),
 		fmt.Sprintf("├── Branch: %s", pr.Branch),
+		fmt.Sprintf("├── Base Branch: %s", pr.BaseBranch),
 		fmt.Sprintf("├── Pull Request: #%d (%s %s)", pr.PRNumber, stateStyle.Render(state), stateStyle.Render(stateEmoji)),
 		fmt.Sprintf("├── URL: %s", urlStyle.Render(pr.PRUrl)),
 		fmt.Sprintf("├── Last Applied: %s", pr.LastApplied.Format(time.RFC3339)),
 		fmt.Sprintf("├── Last Commit: %s", pr.LastCommit),
 	}
 
-	if pr.LastDiff != "" {
+	if pr.Fork != "" {
+		tree = append(tree, fmt.Sprintf("├── Fork: %s", pr.Fork))
+	}
+
+	if pr.Verification == types.CommitVerified {
+		tree = append(tree, "├── Signature: verified")
+	} else if pr.Verification != "" {
+		tree = append(tree, fmt.Sprintf("├── Signature: %s (%s)", pr.Verification, pr.VerificationReason))
+	}
+
+	if pr.Draft {
+		tree = append(tree, "├── Draft: waiting for pro pr ready")
+	}
+
+	if pr.AutoMerge != "" {
+		tree = append(tree, fmt.Sprintf("├── Auto-merge: %s", pr.AutoMerge))
+	}
+
+	if pr.MergeCommit != "" {
+		tree = append(tree, fmt.Sprintf("├── Merged: %s at %s", pr.MergeCommit, pr.MergedAt.Format(time.RFC3339)))
+	}
+
+	if pr.UpdateRefused {
+		tree = append(tree, fmt.Sprintf("├── Update refused, %d foreign commits:", len(pr.ForeignCommits)))
+		for _, commit := range pr.ForeignCommits {
+			tree = append(tree, fmt.Sprintf("│   ├── %s", commit))
+		}
+	}
+
+	if pr.Skipped {
+		tree = append(tree, fmt.Sprintf("├── Skipped: %s", redact.String(pr.SkipReason)))
+	}
+
+	if len(pr.Warnings) > 0 {
+		tree = append(tree, "├── Warnings:")
+		for _, warning := range pr.Warnings {
+			tree = append(tree, fmt.Sprintf("│   ├── %s", warning))
+		}
+	}
+
+	if len(pr.Scripts) > 0 {
+		tree = append(tree, "├── Scripts:")
+		for _, script := range pr.Scripts {
+			outcome := scriptOutcomeStyles[script.Outcome].Render(script.Outcome)
+			line := fmt.Sprintf("│   ├── %s: %s (exit %d, policy %s)", script.Name, outcome, script.ExitCode, script.Policy)
+			tree = append(tree, line)
+		}
+	}
+
+	if pr.NoChanges {
+		tree = append(tree, "└── No changes: the scripts changed nothing, no commit was made")
+		fmt.Printf("%s\n", treeStyle.Render(strings.Join(tree, "\n")))
+	} else if pr.LastDiff != "" {
 		tree = append(tree, "└── Changes:")
 		fmt.Printf("%s\n", treeStyle.Render(strings.Join(tree, "\n")))
-		fmt.Printf("%s\n", diffStyle.Render(pr.LastDiff))
+		fmt.Printf("%s\n", diffStyle.Render(redact.String(pr.LastDiff)))
 	} else {
 		tree = append(tree, "└── No changes")
 		fmt.Printf("%s\n", treeStyle.Render(strings.Join(tree, "\n")))
 	}
 }
 
+var planActionStyles = map[string]lipgloss.Style{
+	types.PlanActionCreate:    stateStyles["open"],
+	types.PlanActionUpdate:    stateStyles["merged"],
+	types.PlanActionNoChanges: stateStyles["no-changes"],
+	types.PlanActionSkip:      stateStyles["skipped"],
+	types.PlanActionFail:      stateStyles["closed"],
+}
+
+// PrintPlan prints what applying each planned PR would do, then the totals
+func (p *ConsolePrinter) PrintPlan(entries []types.PlanEntry) {
+	fmt.Println(titleStyle.Render("Plan"))
+
+	counts := make(map[string]int)
+	for _, entry := range entries {
+		counts[entry.Action]++
+
+		tree := []string{
+			fmt.Sprintf("%s %s/%s", planActionStyles[entry.Action].Render(entry.Action), entry.Namespace, entry.Name),
+			fmt.Sprintf("├── Repository: %s", entry.Repository),
+			fmt.Sprintf("├── Branch: %s", entry.Branch),
+		}
+		if entry.PRNumber != 0 {
+			tree = append(tree, fmt.Sprintf("├── Pull Request: #%d", entry.PRNumber))
+		}
+		for _, change := range entry.MetadataChanges {
+			tree = append(tree, fmt.Sprintf("├── Metadata: %s", change))
+		}
+		if entry.BranchDiff != "" && entry.BranchDiff != entry.Diff {
+			tree = append(tree, "├── Changes to the remote branch:")
+			for _, line := range strings.Split(entry.BranchDiff, "\n") {
+				tree = append(tree, "│   "+strings.TrimSpace(line))
+			}
+		}
+
+		switch {
+		case entry.Reason != "":
+			tree = append(tree, fmt.Sprintf("└── %s", entry.Reason))
+		case entry.Diff != "":
+			tree = append(tree, "└── Changes:")
+			for _, line := range strings.Split(entry.Diff, "\n") {
+				tree = append(tree, "    "+strings.TrimSpace(line))
+			}
+		}
+		fmt.Printf("%s\n\n", treeStyle.Render(redact.String(strings.Join(tree, "\n"))))
+	}
+
+	fmt.Println(titleStyle.Render(fmt.Sprintf("Plan: %d to create, %d to update, %d without changes, %d skipped, %d failing",
+		counts[types.PlanActionCreate], counts[types.PlanActionUpdate], counts[types.PlanActionNoChanges],
+		counts[types.PlanActionSkip], counts[types.PlanActionFail])))
+}
+
 func (p *ConsolePrinter) PrintError(format string, args ...interface{}) {
 	errorStyle := lipgloss.NewStyle().
 		Foreground(lipgloss.Color("#ED567A"))
-	fmt.Print(errorStyle.Render(fmt.Sprintf(format, args...)))
+	fmt.Print(errorStyle.Render(redact.String(fmt.Sprintf(format, args...))))
 }
 
 func (p *ConsolePrinter) PrintPRConfig(pr interface{}) {
@@ -143,15 +262,41 @@ func (p *ConsolePrinter) PrintPRConfig(pr interface{}) {
 		return
 	}
 
-	fmt.Printf("\n%s\n", prConfigStyle.Render(string(yamlBytes)))
+	fmt.Printf("\n%s\n", prConfigStyle.Render(redact.String(string(yamlBytes))))
 }
 
 func (p *ConsolePrinter) PrintInfo(format string, args ...interface{}) {
-	fmt.Printf("%s\n", infoStyle.Render(fmt.Sprintf(format, args...)))
+	fmt.Printf("%s\n", infoStyle.Render(redact.String(fmt.Sprintf(format, args...))))
 }
 
 func (p *ConsolePrinter) PrintDiff(diff string) {
-	fmt.Printf("\n%s\n", diffStyle.Render(strings.TrimSpace("Repository changes:\n"+diff)))
+	fmt.Printf("\n%s\n", diffStyle.Render(strings.TrimSpace("Repository changes:\n"+redact.String(diff))))
+}
+
+var (
+	patchHeaderStyle  = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#FFFFFF"))
+	patchHunkStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("#00B4FF"))
+	patchAddedStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("#00FF9F"))
+	patchRemovedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#FF6B8B"))
+)
+
+// PrintPatch prints a unified diff, colored line by line
+func (p *ConsolePrinter) PrintPatch(patch string) {
+	for _, line := range strings.Split(strings.TrimRight(redact.String(patch), "\n"), "\n") {
+		switch {
+		case strings.HasPrefix(line, "diff --git"), strings.HasPrefix(line, "index "),
+			strings.HasPrefix(line, "--- "), strings.HasPrefix(line, "+++ "),
+			strings.HasPrefix(line, "new file"), strings.HasPrefix(line, "deleted file"):
+			line = patchHeaderStyle.Render(line)
+		case strings.HasPrefix(line, "@@"):
+			line = patchHunkStyle.Render(line)
+		case strings.HasPrefix(line, "+"):
+			line = patchAddedStyle.Render(line)
+		case strings.HasPrefix(line, "-"):
+			line = patchRemovedStyle.Render(line)
+		}
+		fmt.Println(line)
+	}
 }
 
 func (p *ConsolePrinter) PrintScriptOutput(script string, output []byte, err error) {
@@ -164,11 +309,11 @@ func (p *ConsolePrinter) PrintScriptOutput(script string, output []byte, err err
 	if err != nil {
 		tabStyle = tabStyle.Foreground(lipgloss.Color("#FF0000"))
 	}
-	activeTab := tabStyle.Render(fmt.Sprintf("Script %s", script))
+	activeTab := tabStyle.Render(fmt.Sprintf("Script %s", redact.String(script)))
 	tabs := []string{activeTab}
 
 	// Format the output
-	outputStr := strings.TrimSpace(string(output))
+	outputStr := strings.TrimSpace(redact.String(string(output)))
 	outputLines := strings.Split(outputStr, "\n")
 
 	// Build the content
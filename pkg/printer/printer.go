@@ -12,6 +12,8 @@ type Printer interface {
 	PrintPRConfig(pr interface{})
 	PrintInfo(format string, args ...interface{})
 	PrintDiff(diff string)
+	PrintPatch(patch string)
+	PrintPlan(entries []types.PlanEntry)
 	PrintScriptOutput(script string, output []byte, err error)
 	PrintPRSummary(namespace, name, repo, branch string, prNumber int, prURL, commit string, hasChanges bool)
 }
@@ -0,0 +1,116 @@
+package pullrequest
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/nsxbet/proliferate/pkg/mygit"
+	"github.com/nsxbet/proliferate/pkg/types"
+)
+
+// MergeOptions controls how, and under which conditions, pull requests are merged
+type MergeOptions struct {
+	Method       string
+	MinApprovals int
+	// AllowNoChecks merges pull requests on which no CI reported anything
+	AllowNoChecks bool
+	DryRun        bool
+}
+
+// Merge merges the pull requests in prs whose checks passed and that have
+// enough approvals, then reports the ones it skipped and why
+func (m *PRStatusManager) Merge(ctx context.Context, git *mygit.Git, namespace string, prs map[string]PRStatus, opts MergeOptions) error {
+	names := make([]string, 0, len(prs))
+	for name := range prs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	merged := 0
+	var skipped []string
+	var errors []error
+	for _, name := range names {
+		reason, err := m.merge(ctx, git, namespace, name, prs[name], opts)
+		switch {
+		case err != nil:
+			m.printer.PrintError("Failed to merge %s: %v\n", name, err)
+			errors = append(errors, err)
+		case reason != "":
+			skipped = append(skipped, fmt.Sprintf("%s: %s", name, reason))
+		default:
+			merged++
+		}
+	}
+
+	if opts.DryRun {
+		m.printer.PrintInfo("Would merge %d of %d pull requests", merged, len(prs))
+	} else {
+		m.printer.PrintInfo("Merged %d of %d pull requests", merged, len(prs))
+	}
+	if len(skipped) > 0 {
+		m.printer.PrintInfo("Skipped %d pull requests:", len(skipped))
+		for _, s := range skipped {
+			m.printer.PrintInfo("  %s", s)
+		}
+	}
+
+	if len(errors) > 0 {
+		return fmt.Errorf("failed to merge %d pull requests: %v", len(errors), errors)
+	}
+	return nil
+}
+
+// merge merges a single PR, returning why it was skipped when it was not ready
+func (m *PRStatusManager) merge(ctx context.Context, git *mygit.Git, namespace, name string, pr PRStatus, opts MergeOptions) (string, error) {
+	if pr.PRNumber == 0 {
+		return "no pull request was opened", nil
+	}
+
+	state, err := git.MergeState(ctx, pr.Repository, pr.PRNumber)
+	if err != nil {
+		return "", err
+	}
+	if reason := mergeBlocker(state, opts); reason != "" {
+		return reason, nil
+	}
+
+	if opts.DryRun {
+		m.printer.PrintInfo("[dry-run] Merging %s PR #%d in %s", name, pr.PRNumber, pr.Repository)
+		return "", nil
+	}
+
+	m.printer.PrintInfo("Merging %s PR #%d in %s", name, pr.PRNumber, pr.Repository)
+	sha, err := git.MergePR(ctx, pr.Repository, pr.PRNumber, opts.Method, state.HeadSHA)
+	if err != nil {
+		return "", err
+	}
+
+	return "", m.UpdatePRStatus(namespace, name, func(status *types.PRStatus) {
+		status.MergeCommit = sha
+		status.MergedAt = time.Now()
+	})
+}
+
+// mergeBlocker returns why a PR cannot be merged yet, or an empty string when it can
+func mergeBlocker(state *mygit.MergeState, opts MergeOptions) string {
+	switch {
+	case state.State == "merged":
+		return "already merged"
+	case state.State != "open":
+		return fmt.Sprintf("pull request is %s", state.State)
+	case state.ChangesRequested:
+		return "changes were requested"
+	case state.Approvals < opts.MinApprovals:
+		return fmt.Sprintf("%d of %d required approvals", state.Approvals, opts.MinApprovals)
+	case state.Checks == mygit.ChecksFailure:
+		return "checks failed"
+	case state.Checks == mygit.ChecksPending:
+		return "checks are still running"
+	case state.Checks == mygit.ChecksNone && !opts.AllowNoChecks:
+		return "no checks reported"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,102 @@
+package pullrequest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// maxPatchSize caps the stored patch of a PR, since generated or vendored
+// files can make patches arbitrarily large
+const maxPatchSize = 1 << 20
+
+// patchTruncatedNote ends a patch cut at maxPatchSize
+const patchTruncatedNote = "\n... patch truncated at %d bytes\n"
+
+// patchPath is the file holding the last patch of a PR, next to status.yaml
+func (m *PRStatusManager) patchPath(namespace, name string) string {
+	return filepath.Join(m.statusDir, "patches", namespace, name+".patch")
+}
+
+// SavePatch stores the last patch of a PR, cut at a line boundary when it
+// exceeds maxPatchSize, and reports whether it was cut
+func (m *PRStatusManager) SavePatch(namespace, name, patch string) (bool, error) {
+	truncated := len(patch) > maxPatchSize
+	if truncated {
+		cut := strings.LastIndex(patch[:maxPatchSize], "\n") + 1
+		if cut == 0 {
+			cut = maxPatchSize
+		}
+		patch = patch[:cut] + fmt.Sprintf(patchTruncatedNote, maxPatchSize)
+	}
+
+	path := m.patchPath(namespace, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return false, fmt.Errorf("failed to create patch directory: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(patch), 0644); err != nil {
+		return false, fmt.Errorf("failed to write patch file: %v", err)
+	}
+	return truncated, nil
+}
+
+// LoadPatch returns the last patch of a PR, or an empty string when none was stored
+func (m *PRStatusManager) LoadPatch(namespace, name string) (string, error) {
+	data, err := os.ReadFile(m.patchPath(namespace, name))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read patch file: %v", err)
+	}
+	return string(data), nil
+}
+
+// RemovePatch deletes the stored patch of a PR, and its namespace directory once empty
+func (m *PRStatusManager) RemovePatch(namespace, name string) error {
+	path := m.patchPath(namespace, name)
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove patch file: %v", err)
+	}
+	// Fails while other patches remain, which is fine
+	os.Remove(filepath.Dir(path))
+	return nil
+}
+
+// ShowDiffs prints the last patch of each PR in prs, or with stat only its
+// diff stat
+func (m *PRStatusManager) ShowDiffs(namespace string, prs map[string]PRStatus, stat bool) error {
+	names := make([]string, 0, len(prs))
+	for name := range prs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		pr := prs[name]
+		m.printer.PrintInfo("%s (%s)", name, pr.Repository)
+
+		if pr.NoChanges || pr.LastDiff == "" {
+			m.printer.PrintInfo("No changes")
+			continue
+		}
+		if stat {
+			m.printer.PrintDiff(pr.LastDiff)
+			continue
+		}
+
+		patch, err := m.LoadPatch(namespace, name)
+		if err != nil {
+			return err
+		}
+		if patch == "" {
+			m.printer.PrintInfo("No patch recorded, showing the diff stat. Apply the PR again to record its patch.")
+			m.printer.PrintDiff(pr.LastDiff)
+			continue
+		}
+		m.printer.PrintPatch(patch)
+	}
+	return nil
+}
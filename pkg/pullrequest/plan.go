@@ -0,0 +1,250 @@
+package pullrequest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/nsxbet/proliferate/pkg/mygit"
+	"github.com/nsxbet/proliferate/pkg/printer"
+	"github.com/nsxbet/proliferate/pkg/redact"
+	"github.com/nsxbet/proliferate/pkg/types"
+	"gopkg.in/yaml.v3"
+)
+
+// NewPlannedSet builds the pull requests of a plan. ProcessPR then only runs
+// the planned actions and fails a PR whose repository changed since.
+func NewPlannedSet(plan *types.Plan, git *mygit.Git, printer printer.Printer) *PullRequestSet {
+	set := &PullRequestSet{
+		git:     git,
+		status:  NewPRStatusManager(".proliferate", printer),
+		printer: printer,
+		planned: plan.Entries,
+	}
+	for _, entry := range plan.Entries {
+		set.prs = append(set.prs, entry.PullRequest)
+	}
+	return set
+}
+
+func (prs *PullRequestSet) plannedEntry(index int) *types.PlanEntry {
+	if index >= len(prs.planned) {
+		return nil
+	}
+	return &prs.planned[index]
+}
+
+// runsOnApply reports whether apply acts on a planned entry. A PR already up
+// to date is left alone, while one whose scripts changed nothing may still be
+// closed by closeIfNoChanges.
+func runsOnApply(entry types.PlanEntry) bool {
+	switch entry.Action {
+	case types.PlanActionCreate, types.PlanActionUpdate:
+		return true
+	case types.PlanActionNoChanges:
+		return entry.Diff == ""
+	default:
+		return false
+	}
+}
+
+func planChanged(what string) error {
+	return fmt.Errorf("%s changed since the plan was made, run pro pr plan again", what)
+}
+
+func patchDigest(patch string) string {
+	sum := sha256.Sum256([]byte(patch))
+	return hex.EncodeToString(sum[:])
+}
+
+// PlanPR works out what applying pr would do without pushing, forking, opening
+// PRs or recording any status. The clone is always removed.
+func (prs *PullRequestSet) PlanPR(ctx context.Context, pr PullRequest) types.PlanEntry {
+	entry := types.PlanEntry{
+		Namespace:   pr.Metadata.Namespace,
+		Name:        pr.Metadata.Name,
+		Repository:  pr.Spec.Repo,
+		Branch:      pr.Spec.Branch,
+		PullRequest: pr,
+	}
+	if err := prs.plan(ctx, pr, &entry); err != nil {
+		entry.Action = types.PlanActionFail
+		entry.Reason = redact.String(err.Error())
+	}
+	return entry
+}
+
+func (prs *PullRequestSet) plan(ctx context.Context, pr PullRequest, entry *types.PlanEntry) error {
+	repoDir, err := prs.git.Clone(pr.Spec.Repo, cloneOptions(pr))
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(repoDir)
+
+	if entry.BaseCommit, err = prs.git.GetCommitID(repoDir); err != nil {
+		return err
+	}
+	if entry.BaseBranch, err = prs.git.ResolveBaseBranch(ctx, pr.Spec.Repo, repoDir, pr.Spec.BaseBranch); err != nil {
+		return err
+	}
+
+	remote, _, err := prs.prepareRemote(ctx, repoDir, pr, true)
+	if err != nil {
+		return err
+	}
+	branch, err := prs.prepareBranch(repoDir, pr, remote, entry.BaseBranch)
+	if err != nil {
+		return err
+	}
+	entry.RemoteHead = branch.remoteHead
+
+	_, skip, err := prs.runScripts(repoDir, pr)
+	if skip {
+		entry.Action = types.PlanActionSkip
+		entry.Reason = redact.String(err.Error())
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if entry.Diff, err = prs.git.Diff(repoDir); err != nil {
+		return err
+	}
+	patch, err := prs.git.Patch(repoDir)
+	if err != nil {
+		return err
+	}
+	entry.PatchSHA256 = patchDigest(patch)
+
+	previous, err := prs.status.Get(pr.Metadata.Namespace, pr.Metadata.Name)
+	if err != nil {
+		return err
+	}
+	open := false
+	if previous.PRNumber != 0 {
+		state, err := prs.git.GetPRStatus(ctx, pr.Spec.Repo, previous.PRNumber)
+		if err != nil {
+			return err
+		}
+		open = state == "open"
+	}
+
+	if entry.Diff == "" {
+		entry.Action = types.PlanActionNoChanges
+		entry.Reason = "the scripts changed nothing"
+		if pr.Spec.CloseIfNoChanges && branch.fromBase && open {
+			entry.PRNumber = previous.PRNumber
+			entry.Reason = fmt.Sprintf("the scripts changed nothing, PR #%d would be closed", previous.PRNumber)
+		}
+		return nil
+	}
+
+	if branch.remoteHead != "" {
+		if entry.BranchDiff, err = prs.git.DiffRemoteBranch(repoDir, remote, pr.Spec.Branch); err != nil {
+			return err
+		}
+	}
+	if !open {
+		entry.Action = types.PlanActionCreate
+		return nil
+	}
+
+	entry.Action = types.PlanActionUpdate
+	entry.PRNumber = previous.PRNumber
+	entry.MetadataChanges = prs.metadataChanges(ctx, pr, previous.PRNumber)
+	if branch.remoteHead != "" && entry.BranchDiff == "" && len(entry.MetadataChanges) == 0 {
+		entry.Action = types.PlanActionNoChanges
+		entry.Reason = fmt.Sprintf("PR #%d is up to date", previous.PRNumber)
+	}
+	return nil
+}
+
+// metadataChanges compares an open PR with the title, body, labels and
+// assignees applying pr would give it
+func (prs *PullRequestSet) metadataChanges(ctx context.Context, pr PullRequest, number int) []string {
+	current, err := prs.git.DescribePR(ctx, pr.Spec.Repo, number)
+	if err != nil {
+		return []string{fmt.Sprintf("not compared: %v", err)}
+	}
+
+	var changes []string
+	if current.Title != pr.Spec.PRTitle {
+		changes = append(changes, fmt.Sprintf("title: %q -> %q", current.Title, pr.Spec.PRTitle))
+	}
+	if strings.TrimSpace(current.Body) != strings.TrimSpace(pr.Spec.PRBody) {
+		changes = append(changes, "body")
+	}
+	if current.OnlyTitleAndBody {
+		return changes
+	}
+	if !sameNames(current.Labels, pr.Spec.PRLabels) {
+		changes = append(changes, fmt.Sprintf("labels: %v -> %v", current.Labels, pr.Spec.PRLabels))
+	}
+	if !sameNames(current.Assignees, pr.Spec.PRAssignees) {
+		changes = append(changes, fmt.Sprintf("assignees: %v -> %v", current.Assignees, pr.Spec.PRAssignees))
+	}
+	return changes
+}
+
+// sameNames compares labels or logins regardless of order and case
+func sameNames(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	normalize := func(names []string) []string {
+		out := make([]string, len(names))
+		for i, name := range names {
+			out[i] = strings.ToLower(name)
+		}
+		sort.Strings(out)
+		return out
+	}
+	x, y := normalize(a), normalize(b)
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// SavePlan writes a plan for pro pr apply --plan
+func SavePlan(path string, plan types.Plan) error {
+	data, err := yaml.Marshal(plan)
+	if err != nil {
+		return fmt.Errorf("failed to marshal plan: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create plan directory: %v", err)
+	}
+	// The rendered pull requests may hold secrets from the values
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write plan file: %v", err)
+	}
+	// WriteFile keeps the mode of an existing file
+	if err := os.Chmod(path, 0600); err != nil {
+		return fmt.Errorf("failed to write plan file: %v", err)
+	}
+	return nil
+}
+
+func LoadPlan(path string) (*types.Plan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plan file: %v", err)
+	}
+	var plan types.Plan
+	if err := yaml.Unmarshal(data, &plan); err != nil {
+		return nil, fmt.Errorf("failed to parse plan file: %v", err)
+	}
+	if len(plan.Entries) == 0 {
+		return nil, fmt.Errorf("plan file %s has no entries", path)
+	}
+	return &plan, nil
+}
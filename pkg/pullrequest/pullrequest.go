@@ -3,27 +3,41 @@ package pullrequest
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
+	"text/template"
 	"time"
 
 	"github.com/nsxbet/proliferate/pkg/mygit"
 	"github.com/nsxbet/proliferate/pkg/printer"
+	"github.com/nsxbet/proliferate/pkg/redact"
 	"github.com/nsxbet/proliferate/pkg/types"
 	"gopkg.in/yaml.v3"
 )
 
 type PullRequest = types.PullRequest
 
+// noChangesComment is left on PRs closed by closeIfNoChanges
+const noChangesComment = "Closed by proliferate: the scripts no longer produce any changes for this repository."
+
+// forkRemote is the name of the remote a PR branch is pushed to in fork mode
+const forkRemote = "fork"
+
 type PullRequestSet struct {
 	prs            []PullRequest
 	git            *mygit.Git
 	status         *PRStatusManager
 	templateString string
 	printer        printer.Printer
+	// fromFilter is set when the PRs were expanded from a PullRequestFilter
+	fromFilter bool
+	// planned holds the plan entry of each PR when applying a plan
+	planned []types.PlanEntry
 }
 
 func NewPullRequestSet(yamlTemplate string, git *mygit.Git, printer printer.Printer) (*PullRequestSet, error) {
@@ -51,6 +65,7 @@ func NewPullRequestSet(yamlTemplate string, git *mygit.Git, printer printer.Prin
 				status:         NewPRStatusManager(".proliferate", printer),
 				templateString: yamlTemplate,
 				printer:        printer,
+				fromFilter:     true,
 			}, nil
 		}
 
@@ -119,41 +134,103 @@ func (prs *PullRequestSet) ProcessPR(ctx context.Context, index int, pr PullRequ
 	prs.printer.PrintNamespaceHeader(fmt.Sprintf("Pull Request %d", index+1))
 	prs.printer.PrintPRConfig(pr)
 
-	repoDir, err := prs.git.Clone(pr.Spec.Repo)
+	planned := prs.plannedEntry(index)
+	if planned != nil && !runsOnApply(*planned) {
+		prs.printer.PrintInfo("Skipping %s, planned as %s", pr.Spec.Repo, planned.Action)
+		return nil
+	}
+
+	repoDir, err := prs.git.Clone(pr.Spec.Repo, cloneOptions(pr))
 	if err != nil {
 		return err
 	}
-	if !dryRun {
-		defer os.RemoveAll(repoDir)
-	}
+	defer os.RemoveAll(repoDir)
 	prs.printer.PrintInfo("Cloned repository to: %s", repoDir)
 
-	if err := prs.git.CreateBranch(repoDir, pr.Spec.Branch); err != nil {
+	baseCommit, err := prs.git.GetCommitID(repoDir)
+	if err != nil {
 		return err
 	}
+	if planned != nil && planned.BaseCommit != baseCommit {
+		return planChanged("the base branch")
+	}
 
-	// Will halt if any script fails
-	for _, script := range pr.Spec.Scripts {
-		if err := prs.runScript(repoDir, script, pr.Spec.ScriptsContext, pr.Metadata.Name, pr.Metadata.Namespace); err != nil {
-			return err
+	baseBranch, err := prs.git.ResolveBaseBranch(ctx, pr.Spec.Repo, repoDir, pr.Spec.BaseBranch)
+	if err != nil {
+		return err
+	}
+	prs.printer.PrintInfo("Using base branch: %s", baseBranch)
+
+	remote, fork, err := prs.prepareRemote(ctx, repoDir, pr, dryRun)
+	if err != nil {
+		return err
+	}
+
+	branch, err := prs.prepareBranch(repoDir, pr, remote, baseBranch)
+	if err != nil {
+		prs.recordRefusedUpdate(pr, err, dryRun)
+		return err
+	}
+	if planned != nil && planned.RemoteHead != branch.remoteHead {
+		return planChanged("the remote branch")
+	}
+
+	scriptResults, skip, err := prs.runScripts(repoDir, pr)
+	if skip {
+		if dryRun {
+			return nil
+		}
+		if updateErr := prs.status.UpdatePRStatus(pr.Metadata.Namespace, pr.Metadata.Name, func(status *types.PRStatus) {
+			status.Name = pr.Metadata.Name
+			status.Repository = pr.Spec.Repo
+			status.Branch = pr.Spec.Branch
+			status.Skipped = true
+			status.SkipReason = redact.String(err.Error())
+			status.Scripts = scriptResults
+		}); updateErr != nil {
+			return fmt.Errorf("failed to update PR status: %v", updateErr)
+		}
+		return nil
+	}
+	if err != nil {
+		if !dryRun {
+			if updateErr := prs.status.UpdatePRStatus(pr.Metadata.Namespace, pr.Metadata.Name, func(status *types.PRStatus) {
+				status.LastError = redact.String(err.Error())
+				status.LastErrorAt = time.Now()
+				status.Scripts = scriptResults
+			}); updateErr != nil {
+				prs.printer.PrintError("Failed to update status: %v", updateErr)
+			}
 		}
+		return err
 	}
 
 	diffOutput, err := prs.git.Diff(repoDir)
 	if err != nil {
 		return err
 	}
-	if len(diffOutput) > 0 {
-		prs.printer.PrintDiff(diffOutput)
-	} else {
-		prs.printer.PrintInfo("No changes in repository")
+	patch, err := prs.git.Patch(repoDir)
+	if err != nil {
+		return err
+	}
+	if planned != nil && planned.PatchSHA256 != patchDigest(patch) {
+		return planChanged("the changes made by the scripts")
 	}
 
+	if len(diffOutput) == 0 {
+		prs.printer.PrintInfo("No changes in repository, nothing to commit")
+		return prs.recordNoChanges(ctx, pr, baseBranch, branch.fromBase, scriptResults, dryRun)
+	}
+	prs.printer.PrintDiff(diffOutput)
+
 	if err := prs.git.Add(repoDir); err != nil {
 		return err
 	}
 
-	if err := prs.git.Commit(repoDir, pr.Spec.CommitMessage); err != nil {
+	if err := prs.git.Commit(repoDir, pr.Spec.CommitMessage, mygit.CommitOptions{
+		Author:    pr.Spec.CommitAuthor,
+		CoAuthors: pr.Spec.CoAuthors,
+	}); err != nil {
 		return err
 	}
 
@@ -161,31 +238,58 @@ func (prs *PullRequestSet) ProcessPR(ctx context.Context, index int, pr PullRequ
 		return nil
 	}
 
-	if err := prs.git.Push(repoDir, pr.Spec.Branch); err != nil {
+	expectedHead := ""
+	if branch.force {
+		expectedHead = branch.remoteHead
+	}
+	if err := prs.git.Push(repoDir, remote, pr.Spec.Branch, expectedHead); err != nil {
 		return err
 	}
 
-	owner, repoName, err := prs.git.ParseRepoString(pr.Spec.Repo)
+	headOwner := ""
+	if fork != "" {
+		if _, headOwner, _, err = prs.git.ParseRepoString(fork); err != nil {
+			return err
+		}
+	}
+
+	createdPR, err := prs.git.CreatePR(ctx, pr.Spec.Repo, mygit.PROptions{
+		Branch:        pr.Spec.Branch,
+		Base:          baseBranch,
+		Title:         pr.Spec.PRTitle,
+		Body:          pr.Spec.PRBody,
+		Labels:        pr.Spec.PRLabels,
+		Assignees:     pr.Spec.PRAssignees,
+		Reviewers:     pr.Spec.PRReviewers,
+		TeamReviewers: pr.Spec.PRTeamReviewers,
+		Draft:         pr.Spec.Draft,
+		HeadOwner:     headOwner,
+	})
 	if err != nil {
 		return err
 	}
+	var warnings []string
+	for _, warning := range createdPR.Warnings {
+		prs.printer.PrintError("Warning: PR #%d: %s\n", createdPR.Number, warning)
+		warnings = append(warnings, redact.String(warning))
+	}
 
-	createdPR, err := prs.git.CreatePR(
-		ctx,
-		owner,
-		repoName,
-		pr.Spec.Branch,
-		"main",
-		pr.Spec.PRTitle,
-		pr.Spec.PRBody,
-		pr.Spec.PRLabels,
-		pr.Spec.PRAssignees,
-	)
+	commitID, err := prs.git.GetCommitID(repoDir)
 	if err != nil {
 		return err
 	}
 
-	commitID, err := prs.git.GetCommitID(repoDir)
+	verification, verificationReason := "", ""
+	if prs.git.SigningEnabled() {
+		verification, verificationReason = prs.verifyCommit(ctx, pr, fork, commitID)
+	}
+
+	autoMerge := ""
+	if pr.Spec.AutoMerge != nil && pr.Spec.AutoMerge.Enabled {
+		autoMerge = prs.enableAutoMerge(ctx, pr, createdPR)
+	}
+
+	patchTruncated, err := prs.status.SavePatch(pr.Metadata.Namespace, pr.Metadata.Name, redact.String(patch))
 	if err != nil {
 		return err
 	}
@@ -194,11 +298,25 @@ func (prs *PullRequestSet) ProcessPR(ctx context.Context, index int, pr PullRequ
 		status.Name = pr.Metadata.Name
 		status.LastApplied = time.Now()
 		status.Branch = pr.Spec.Branch
+		status.BaseBranch = baseBranch
 		status.Repository = pr.Spec.Repo
+		status.Fork = fork
 		status.LastDiff = diffOutput
+		status.PatchTruncated = patchTruncated
 		status.LastCommit = commitID
-		status.PRNumber = createdPR.GetNumber()
-		status.PRUrl = createdPR.GetHTMLURL()
+		status.Verification = verification
+		status.VerificationReason = verificationReason
+		status.PRNumber = createdPR.Number
+		status.PRUrl = createdPR.URL
+		status.Draft = createdPR.Draft
+		status.AutoMerge = autoMerge
+		status.UpdateRefused = false
+		status.ForeignCommits = nil
+		status.NoChanges = false
+		status.Skipped = false
+		status.SkipReason = ""
+		status.Scripts = scriptResults
+		status.Warnings = warnings
 	}); err != nil {
 		return fmt.Errorf("failed to update PR status: %v", err)
 	}
@@ -209,8 +327,8 @@ func (prs *PullRequestSet) ProcessPR(ctx context.Context, index int, pr PullRequ
 		pr.Metadata.Name,
 		pr.Spec.Repo,
 		pr.Spec.Branch,
-		createdPR.GetNumber(),
-		createdPR.GetHTMLURL(),
+		createdPR.Number,
+		createdPR.URL,
 		commitID,
 		len(diffOutput) > 0,
 	)
@@ -218,34 +336,365 @@ func (prs *PullRequestSet) ProcessPR(ctx context.Context, index int, pr PullRequ
 	return nil
 }
 
-func (prs *PullRequestSet) runScript(repoDir string, script string, context map[string]string, prName string, namespace string) error {
+// verifyCommit returns the verification state of a pushed commit and, when it
+// is unverified, the reason given by the host
+func (prs *PullRequestSet) verifyCommit(ctx context.Context, pr PullRequest, fork string, commitID string) (string, string) {
+	repo := pr.Spec.Repo
+	if fork != "" {
+		repo = fork
+	}
+
+	verification, err := prs.git.CommitVerification(ctx, repo, commitID)
+	if err != nil {
+		prs.printer.PrintError("Warning: could not check the signature of commit %s: %v\n", commitID, err)
+		return "", ""
+	}
+	if verification.Verified {
+		return types.CommitVerified, ""
+	}
+	prs.printer.PrintError("Warning: commit %s is not verified by the host: %s\n", commitID, verification.Reason)
+	return types.CommitUnverified, verification.Reason
+}
+
+// enableAutoMerge arms auto-merge on a created PR and returns the resulting
+// auto-merge state. A failure is only a warning since the PR itself is fine.
+func (prs *PullRequestSet) enableAutoMerge(ctx context.Context, pr PullRequest, createdPR *mygit.PullRequest) string {
+	headline, err := renderCommitHeadline(pr.Spec.AutoMerge.CommitHeadline, pr, createdPR)
+	if err == nil {
+		err = prs.git.EnableAutoMerge(ctx, pr.Spec.Repo, createdPR.Number, mygit.AutoMergeOptions{
+			Method:         pr.Spec.AutoMerge.MergeMethod,
+			CommitHeadline: headline,
+		})
+	}
+	if err != nil {
+		prs.printer.PrintError("Warning: could not enable auto-merge on PR #%d: %v\n", createdPR.Number, err)
+		return types.AutoMergeFailed
+	}
+
+	prs.printer.PrintInfo("Auto-merge enabled on PR #%d", createdPR.Number)
+	return types.AutoMergeArmed
+}
+
+func renderCommitHeadline(headline string, pr PullRequest, createdPR *mygit.PullRequest) (string, error) {
+	if headline == "" {
+		return "", nil
+	}
+
+	t, err := template.New("commitHeadline").Parse(headline)
+	if err != nil {
+		return "", fmt.Errorf("invalid autoMerge.commitHeadline: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, map[string]interface{}{
+		"Number": createdPR.Number,
+		"Title":  pr.Spec.PRTitle,
+		"Repo":   pr.Spec.Repo,
+		"Branch": pr.Spec.Branch,
+		"URL":    createdPR.URL,
+	}); err != nil {
+		return "", fmt.Errorf("failed to render autoMerge.commitHeadline: %v", err)
+	}
+	return buf.String(), nil
+}
+
+// recordNoChanges finishes a run whose scripts changed nothing. Nothing is
+// committed or pushed, and with closeIfNoChanges the open PR is closed since
+// the branch would no longer change anything. A branch keeping foreign
+// commits is left with its previous status.
+func (prs *PullRequestSet) recordNoChanges(ctx context.Context, pr PullRequest, baseBranch string, fromBase bool, scriptResults []types.ScriptStatus, dryRun bool) error {
+	if dryRun {
+		return nil
+	}
+
+	// A branch kept for its foreign commits still carries changes
+	if !fromBase {
+		prs.printer.PrintInfo("Leaving %s as it is, its commits not made by proliferate still change the repository", pr.Spec.Branch)
+		return nil
+	}
+
+	previous, err := prs.status.Get(pr.Metadata.Namespace, pr.Metadata.Name)
+	if err != nil {
+		return err
+	}
+
+	if pr.Spec.CloseIfNoChanges && previous.PRNumber != 0 {
+		state, err := prs.git.GetPRStatus(ctx, pr.Spec.Repo, previous.PRNumber)
+		if err != nil {
+			return err
+		}
+		if state == "open" {
+			prs.printer.PrintInfo("Closing PR #%d, it is no longer needed", previous.PRNumber)
+			if err := prs.git.ClosePR(ctx, pr.Spec.Repo, previous.PRNumber, noChangesComment); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := prs.status.RemovePatch(pr.Metadata.Namespace, pr.Metadata.Name); err != nil {
+		return err
+	}
+	if err := prs.status.UpdatePRStatus(pr.Metadata.Namespace, pr.Metadata.Name, func(status *types.PRStatus) {
+		status.Name = pr.Metadata.Name
+		status.LastApplied = time.Now()
+		status.Branch = pr.Spec.Branch
+		status.BaseBranch = baseBranch
+		status.Repository = pr.Spec.Repo
+		status.LastDiff = ""
+		status.PatchTruncated = false
+		status.NoChanges = true
+		status.UpdateRefused = false
+		status.ForeignCommits = nil
+		status.Skipped = false
+		status.SkipReason = ""
+		status.Scripts = scriptResults
+	}); err != nil {
+		return fmt.Errorf("failed to update PR status: %v", err)
+	}
+	return nil
+}
+
+// prepareRemote returns the remote the PR branch is pushed to, and the fork
+// behind it in fork mode. A dry run does not create forks, so without an
+// earlier fork it returns no remote at all.
+func (prs *PullRequestSet) prepareRemote(ctx context.Context, repoDir string, pr PullRequest, dryRun bool) (string, string, error) {
+	if pr.Spec.Fork == nil || !pr.Spec.Fork.Enabled {
+		return "origin", "", nil
+	}
+
+	var fork string
+	if dryRun {
+		previous, err := prs.status.Get(pr.Metadata.Namespace, pr.Metadata.Name)
+		if err != nil {
+			return "", "", err
+		}
+		if previous.Fork == "" {
+			prs.printer.PrintInfo("Would fork %s", pr.Spec.Repo)
+			return "", "", nil
+		}
+		fork = previous.Fork
+	} else {
+		var err error
+		if fork, err = prs.git.Fork(ctx, pr.Spec.Repo, pr.Spec.Fork.Owner); err != nil {
+			return "", "", err
+		}
+	}
+
+	if err := prs.git.AddRemote(repoDir, forkRemote, fork); err != nil {
+		return "", "", err
+	}
+	prs.printer.PrintInfo("Using fork: %s", fork)
+	return forkRemote, fork, nil
+}
+
+// preparedBranch is the PR branch as checked out by prepareBranch
+type preparedBranch struct {
+	// remoteHead is the head of the existing remote branch, if any
+	remoteHead string
+	// force allows the push to overwrite remoteHead
+	force bool
+	// fromBase is set when the branch holds nothing but base and the regenerated change
+	fromBase bool
+}
+
+// refusedUpdateError stops a PR whose branch holds commits proliferate did not make
+type refusedUpdateError struct {
+	branch   string
+	strategy string
+	foreign  []mygit.BranchCommit
+}
+
+func (e *refusedUpdateError) Error() string {
+	return fmt.Sprintf("branch %s has %d commits not made by proliferate, refusing to update it (updateStrategy: %s)",
+		e.branch, len(e.foreign), e.strategy)
+}
+
+// recordRefusedUpdate records in the status that err refused to update the
+// branch of pr. A dry run leaves the status alone.
+func (prs *PullRequestSet) recordRefusedUpdate(pr PullRequest, err error, dryRun bool) {
+	var refused *refusedUpdateError
+	if dryRun || !errors.As(err, &refused) {
+		return
+	}
+
+	var foreign []string
+	for _, commit := range refused.foreign {
+		foreign = append(foreign, commit.String())
+	}
+	if updateErr := prs.status.UpdatePRStatus(pr.Metadata.Namespace, pr.Metadata.Name, func(status *types.PRStatus) {
+		status.UpdateRefused = true
+		status.ForeignCommits = foreign
+		status.LastError = err.Error()
+		status.LastErrorAt = time.Now()
+	}); updateErr != nil {
+		prs.printer.PrintError("Failed to update status: %v", updateErr)
+	}
+}
+
+// cloneOptions limits the clone of pr to its base branch and checkout settings
+func cloneOptions(pr PullRequest) mygit.CloneOptions {
+	opts := mygit.CloneOptions{Branch: pr.Spec.BaseBranch}
+	if checkout := pr.Spec.Checkout; checkout != nil {
+		opts.Depth = checkout.Depth
+		opts.Filter = checkout.Filter
+		opts.SparsePaths = checkout.SparsePaths
+	}
+	return opts
+}
+
+// prepareBranch checks out the PR branch according to the update strategy
+func (prs *PullRequestSet) prepareBranch(repoDir string, pr PullRequest, remote string, baseBranch string) (preparedBranch, error) {
+	strategy := pr.Spec.UpdateStrategy
+	if strategy == "" {
+		strategy = types.UpdateStrategyRefuseIfModified
+	}
+	switch strategy {
+	case types.UpdateStrategyRecreate, types.UpdateStrategyRebase, types.UpdateStrategyRefuseIfModified:
+	default:
+		return preparedBranch{}, fmt.Errorf("unknown update strategy %q, expected one of %s, %s or %s", strategy,
+			types.UpdateStrategyRecreate, types.UpdateStrategyRebase, types.UpdateStrategyRefuseIfModified)
+	}
+
+	if remote == "" {
+		return preparedBranch{fromBase: true}, prs.git.CreateBranch(repoDir, pr.Spec.Branch, baseBranch)
+	}
+
+	remoteHead, err := prs.git.FetchRemoteBranch(repoDir, remote, pr.Spec.Branch)
+	if err != nil {
+		return preparedBranch{}, err
+	}
+	if remoteHead == "" {
+		return preparedBranch{fromBase: true}, prs.git.CreateBranch(repoDir, pr.Spec.Branch, baseBranch)
+	}
+
+	previous, err := prs.status.Get(pr.Metadata.Namespace, pr.Metadata.Name)
+	if err != nil {
+		return preparedBranch{}, err
+	}
+	var ours []string
+	if previous.LastCommit != "" {
+		ours = append(ours, previous.LastCommit)
+	}
+
+	foreign, err := prs.git.ForeignCommits(repoDir, baseBranch, remote, pr.Spec.Branch, ours)
+	if err != nil {
+		return preparedBranch{}, err
+	}
+
+	regenerated := preparedBranch{remoteHead: remoteHead, force: true, fromBase: true}
+	switch {
+	case len(foreign) == 0:
+		// Only our own commits, regenerate them from base
+		return regenerated, prs.git.CreateBranch(repoDir, pr.Spec.Branch, baseBranch)
+	case strategy == types.UpdateStrategyRecreate:
+		prs.printer.PrintInfo("Overwriting %d commits not made by proliferate on %s", len(foreign), pr.Spec.Branch)
+		return regenerated, prs.git.CreateBranch(repoDir, pr.Spec.Branch, baseBranch)
+	case strategy == types.UpdateStrategyRebase:
+		// Replay the foreign commits on base, the regenerated change goes on top
+		prs.printer.PrintInfo("Rebasing %d commits not made by proliferate on %s onto %s", len(foreign), pr.Spec.Branch, baseBranch)
+		if err := prs.git.CreateBranch(repoDir, pr.Spec.Branch, baseBranch); err != nil {
+			return preparedBranch{}, err
+		}
+		return preparedBranch{remoteHead: remoteHead, force: true}, prs.git.CherryPick(repoDir, foreign)
+	default:
+		return preparedBranch{}, &refusedUpdateError{branch: pr.Spec.Branch, strategy: strategy, foreign: foreign}
+	}
+}
+
+// runScripts runs the scripts of pr in order until the failure policy of one
+// halts the PR. skip reports that the policy skips the repository instead of
+// failing it, and the error is then the reason.
+func (prs *PullRequestSet) runScripts(repoDir string, pr PullRequest) ([]types.ScriptStatus, bool, error) {
+	var scriptResults []types.ScriptStatus
+	for _, step := range pr.Spec.Scripts {
+		policy := types.EffectiveFailurePolicy(pr.Spec.FailurePolicy, step.FailurePolicy)
+		result, err := prs.runScript(repoDir, step, pr.Spec.ScriptsContext, pr.Metadata.Name)
+		result.Policy = policy.Action
+		if err == nil {
+			result.Outcome = types.ScriptOutcomeSucceeded
+			scriptResults = append(scriptResults, result)
+			continue
+		}
+
+		switch {
+		case result.ExitCode > 0 && policy.IgnoresExitCode(result.ExitCode):
+			result.Outcome = types.ScriptOutcomeIgnored
+			prs.printer.PrintInfo("Ignoring exit code %d of script %s", result.ExitCode, step.DisplayName())
+		case policy.Action == types.FailureActionContinue:
+			result.Outcome = types.ScriptOutcomeContinued
+			prs.printer.PrintInfo("Script %s failed, continuing: %v", step.DisplayName(), err)
+		case policy.Action == types.FailureActionSkipRepo:
+			result.Outcome = types.ScriptOutcomeSkipped
+			prs.printer.PrintInfo("Script %s failed, skipping repository %s", step.DisplayName(), pr.Spec.Repo)
+			return append(scriptResults, result), true, fmt.Errorf("script %s failed: %v", step.DisplayName(), err)
+		default:
+			result.Outcome = types.ScriptOutcomeFailed
+			return append(scriptResults, result), false, err
+		}
+		scriptResults = append(scriptResults, result)
+	}
+	return scriptResults, false, nil
+}
+
+func (prs *PullRequestSet) runScript(repoDir string, step types.ScriptStep, context map[string]string, prName string) (types.ScriptStatus, error) {
+	result := types.ScriptStatus{Name: step.DisplayName()}
+
 	env := os.Environ()
 	currentDir, err := os.Getwd()
 	if err != nil {
-		return fmt.Errorf("failed to get current directory: %v", err)
+		return result, fmt.Errorf("failed to get current directory: %v", err)
 	}
 	for k, v := range context {
 		env = append(env, fmt.Sprintf("%s=%s", strings.ToUpper(k), v))
 	}
 	env = append(env, fmt.Sprintf("PRO_ROOT=%s", currentDir))
+	for k, v := range step.Env {
+		env = append(env, fmt.Sprintf("%s=%s", k, v))
+	}
+
+	workDir, err := stepWorkingDir(repoDir, step.WorkingDir)
+	if err != nil {
+		return result, err
+	}
 
-	// Print envs
-	cmd := exec.Command("sh", "-c", script)
-	cmd.Dir = repoDir
+	var cmd *exec.Cmd
+	if len(step.Command) > 0 {
+		cmd = exec.Command(step.Command[0], append(step.Command[1:], step.Args...)...)
+	} else {
+		// Extra args become the script positional parameters ($1, $2, ...)
+		cmd = exec.Command("sh", append([]string{"-c", step.Script, "sh"}, step.Args...)...)
+	}
+	cmd.Dir = workDir
 	cmd.Env = env
+
+	start := time.Now()
 	output, err := cmd.CombinedOutput()
-	prs.printer.PrintScriptOutput(fmt.Sprintf("PR(%s) Script %s", prName, script), output, err)
+	result.Duration = time.Since(start)
+	prs.printer.PrintScriptOutput(fmt.Sprintf("PR(%s) %s", prName, step.DisplayName()), output, err)
 	if err != nil {
-		errMsg := fmt.Sprintf("script failed: %v\n%s", err, output)
-		if updateErr := prs.status.UpdatePRStatus(namespace, prName, func(status *types.PRStatus) {
-			status.LastError = errMsg
-			status.LastErrorAt = time.Now()
-		}); updateErr != nil {
-			prs.printer.PrintError("Failed to update status: %v", updateErr)
+		result.ExitCode = -1
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			result.ExitCode = exitErr.ExitCode()
 		}
-		return fmt.Errorf(errMsg)
+		result.Error = err.Error()
+		return result, fmt.Errorf("script %q failed: %v\n%s", step.DisplayName(), err, output)
 	}
-	return nil
+	return result, nil
+}
+
+// stepWorkingDir resolves a step working directory relative to the repository,
+// refusing paths that escape it
+func stepWorkingDir(repoDir, workingDir string) (string, error) {
+	if workingDir == "" {
+		return repoDir, nil
+	}
+
+	dir := filepath.Join(repoDir, workingDir)
+	rel, err := filepath.Rel(repoDir, dir)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("working directory %q is outside the repository", workingDir)
+	}
+	return dir, nil
 }
 
 // fetchReposAndCreatePRs fetches repositories from GitHub based on org and filter,
@@ -260,23 +709,5 @@ func fetchReposAndCreatePRs(git *mygit.Git, template PullRequest) ([]PullRequest
 	}
 
 	// Create a PR object for each matching repository
-	var prs []PullRequest
-	for _, repo := range repoURLs {
-		// Clone the template PR
-		newPR := template
-
-		// Set the repo field
-		newPR.Spec.Repo = repo
-
-		// Change the kind to PullRequest
-		newPR.Kind = "PullRequest"
-
-		// Add unique identifier to PR name to avoid conflicts
-		repoName := strings.TrimPrefix(repo, "github.com/"+template.Spec.Org+"/")
-		newPR.Metadata.Name = fmt.Sprintf("%s-%s", template.Metadata.Name, repoName)
-
-		prs = append(prs, newPR)
-	}
-
-	return prs, nil
+	return fanOut(template, repoURLs), nil
 }
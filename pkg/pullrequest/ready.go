@@ -0,0 +1,55 @@
+package pullrequest
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/nsxbet/proliferate/pkg/mygit"
+	"github.com/nsxbet/proliferate/pkg/types"
+)
+
+// MarkReady takes the draft pull requests in prs out of draft so their owners
+// get to review them
+func (m *PRStatusManager) MarkReady(ctx context.Context, git *mygit.Git, namespace string, prs map[string]PRStatus, dryRun bool) error {
+	names := make([]string, 0, len(prs))
+	for name := range prs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	ready := 0
+	var errors []error
+	for _, name := range names {
+		pr := prs[name]
+		if pr.PRNumber == 0 || !pr.Draft {
+			continue
+		}
+
+		if dryRun {
+			m.printer.PrintInfo("[dry-run] Marking %s PR #%d in %s ready for review", name, pr.PRNumber, pr.Repository)
+			ready++
+			continue
+		}
+
+		m.printer.PrintInfo("Marking %s PR #%d in %s ready for review", name, pr.PRNumber, pr.Repository)
+		if err := git.MarkReady(ctx, pr.Repository, pr.PRNumber); err != nil {
+			m.printer.PrintError("Failed to mark %s ready: %v\n", name, err)
+			errors = append(errors, err)
+			continue
+		}
+		if err := m.UpdatePRStatus(namespace, name, func(status *types.PRStatus) {
+			status.Draft = false
+		}); err != nil {
+			errors = append(errors, err)
+			continue
+		}
+		ready++
+	}
+
+	m.printer.PrintInfo("%d draft pull requests ready for review", ready)
+	if len(errors) > 0 {
+		return fmt.Errorf("failed to mark %d pull requests ready: %v", len(errors), errors)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package pullrequest
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nsxbet/proliferate/pkg/mygit"
+)
+
+const (
+	// ConfigReposOverride fans every pull request out across the configured repos
+	ConfigReposOverride = "override"
+	// ConfigReposIntersect keeps only pull requests targeting a configured repo
+	ConfigReposIntersect = "intersect"
+)
+
+// ApplyConfigRepos reconciles the set with the `repo` list from config.yaml.
+// Pull requests without a repo are always fanned out across the configured
+// repositories; mode decides what happens to the ones that declare a repo.
+// Pull requests expanded from a PullRequestFilter are never fanned out again.
+func (prs *PullRequestSet) ApplyConfigRepos(repos []string, mode string) error {
+	switch mode {
+	case "", ConfigReposOverride, ConfigReposIntersect:
+	default:
+		return fmt.Errorf("unknown config repos mode %q, expected %s or %s", mode, ConfigReposOverride, ConfigReposIntersect)
+	}
+
+	if len(repos) == 0 {
+		if mode != "" {
+			return fmt.Errorf("config repos mode %q requires a repo list in the config file", mode)
+		}
+		return nil
+	}
+
+	configured := make(map[string]bool, len(repos))
+	var normalized []string
+	for _, repo := range repos {
+		repo = mygit.NormalizeRepo(repo)
+		if !configured[repo] {
+			configured[repo] = true
+			normalized = append(normalized, repo)
+		}
+	}
+
+	if mode == ConfigReposOverride && prs.fromFilter {
+		prs.printer.PrintInfo("Keeping the repositories matched by the PullRequestFilter, override only applies to templates")
+	}
+
+	var result []PullRequest
+	for _, pr := range prs.prs {
+		switch {
+		case pr.Spec.Repo == "" || (mode == ConfigReposOverride && !prs.fromFilter):
+			result = append(result, fanOut(pr, normalized)...)
+		case mode == ConfigReposIntersect:
+			if configured[mygit.NormalizeRepo(pr.Spec.Repo)] {
+				result = append(result, pr)
+			} else {
+				prs.printer.PrintInfo("Skipping %s: repository %s is not in the config repo list", pr.Metadata.Name, pr.Spec.Repo)
+			}
+		default:
+			result = append(result, pr)
+		}
+	}
+
+	if len(result) == 0 {
+		return fmt.Errorf("no pull requests left after applying the config repo list")
+	}
+
+	prs.prs = result
+	return nil
+}
+
+// fanOut clones a template pull request once per repository, suffixing the
+// name with the repository name so each one gets its own status entry
+func fanOut(template PullRequest, repos []string) []PullRequest {
+	names := make(map[string]int)
+	for _, repo := range repos {
+		names[repoSuffix(repo, false)]++
+	}
+
+	var prs []PullRequest
+	for _, repo := range repos {
+		newPR := template
+		newPR.Spec.Repo = repo
+		newPR.Kind = "PullRequest"
+
+		// Fall back to owner-repo when two repos share the same name
+		suffix := repoSuffix(repo, names[repoSuffix(repo, false)] > 1)
+		newPR.Metadata.Name = fmt.Sprintf("%s-%s", template.Metadata.Name, suffix)
+
+		prs = append(prs, newPR)
+	}
+	return prs
+}
+
+func repoSuffix(repo string, withOwner bool) string {
+	parts := strings.Split(repo, "/")
+	if withOwner && len(parts) >= 2 {
+		return parts[len(parts)-2] + "-" + parts[len(parts)-1]
+	}
+	return parts[len(parts)-1]
+}
@@ -0,0 +1,225 @@
+package pullrequest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/nsxbet/proliferate/pkg/mygit"
+)
+
+// RetractOptions controls how the pull requests of a namespace are retracted
+type RetractOptions struct {
+	// Comment is left on every PR before it is closed
+	Comment string
+	// Destroy also deletes the remote branches and the status entries
+	Destroy bool
+	// Force deletes branches holding commits proliferate did not make
+	Force  bool
+	DryRun bool
+}
+
+// Select returns the status entries of a namespace, limited to names when any are given
+func (m *PRStatusManager) Select(namespace string, names []string) (map[string]PRStatus, error) {
+	prs, err := m.GetByNamespace(namespace)
+	if err != nil {
+		return nil, err
+	}
+	if prs == nil {
+		return nil, fmt.Errorf("namespace %s not found", namespace)
+	}
+	if len(names) == 0 {
+		return prs, nil
+	}
+
+	selected := make(map[string]PRStatus)
+	for _, name := range names {
+		pr, ok := prs[name]
+		if !ok {
+			return nil, fmt.Errorf("pull request %s not found in namespace %s", name, namespace)
+		}
+		selected[name] = pr
+	}
+	return selected, nil
+}
+
+// Retract closes the open pull requests in prs and, when destroying, deletes
+// their branches and forgets them. It keeps going when a PR fails and returns
+// the failures at the end.
+func (m *PRStatusManager) Retract(ctx context.Context, git *mygit.Git, namespace string, prs map[string]PRStatus, opts RetractOptions) error {
+	names := make([]string, 0, len(prs))
+	for name := range prs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errors []error
+	for _, name := range names {
+		if err := m.retract(ctx, git, namespace, name, prs[name], opts); err != nil {
+			m.printer.PrintError("Failed to retract %s: %v\n", name, err)
+			errors = append(errors, err)
+		}
+	}
+
+	if len(errors) > 0 {
+		return fmt.Errorf("failed to retract %d pull requests: %v", len(errors), errors)
+	}
+	return nil
+}
+
+func (m *PRStatusManager) retract(ctx context.Context, git *mygit.Git, namespace, name string, pr PRStatus, opts RetractOptions) error {
+	prefix := ""
+	if opts.DryRun {
+		prefix = "[dry-run] "
+	}
+
+	// Refuse before closing anything, the PR is left as it is
+	if opts.Destroy && !opts.Force {
+		if err := m.checkForeignCommits(ctx, git, pr); err != nil {
+			return err
+		}
+	}
+
+	if pr.PRNumber != 0 {
+		state, err := git.GetPRStatus(ctx, pr.Repository, pr.PRNumber)
+		if err != nil {
+			return err
+		}
+		if state == "open" {
+			m.printer.PrintInfo("%sClosing %s PR #%d in %s", prefix, name, pr.PRNumber, pr.Repository)
+			if !opts.DryRun {
+				if err := git.ClosePR(ctx, pr.Repository, pr.PRNumber, opts.Comment); err != nil {
+					return err
+				}
+			}
+		} else {
+			m.printer.PrintInfo("%s%s PR #%d in %s is already %s", prefix, name, pr.PRNumber, pr.Repository, state)
+		}
+	}
+
+	if !opts.Destroy {
+		return nil
+	}
+
+	// Fork based PRs keep their branch in the fork
+	branchRepo := pr.Repository
+	if pr.Fork != "" {
+		branchRepo = pr.Fork
+	}
+	if pr.Branch != "" && branchRepo != "" {
+		m.printer.PrintInfo("%sDeleting branch %s of %s", prefix, pr.Branch, branchRepo)
+		if !opts.DryRun {
+			if err := git.DeleteRemoteBranch(branchRepo, pr.Branch); err != nil {
+				return err
+			}
+		}
+	}
+
+	m.printer.PrintInfo("%sRemoving %s from namespace %s", prefix, name, namespace)
+	if !opts.DryRun {
+		if err := m.Remove(namespace, name); err != nil {
+			return err
+		}
+	}
+
+	if pr.Fork == "" {
+		return nil
+	}
+	inUse, err := m.forkInUse(pr.Fork, namespace, name)
+	if err != nil {
+		return err
+	}
+	if inUse {
+		m.printer.PrintInfo("%sKeeping fork %s, other pull requests still use it", prefix, pr.Fork)
+		return nil
+	}
+	m.printer.PrintInfo("%sDeleting fork %s", prefix, pr.Fork)
+	if opts.DryRun {
+		return nil
+	}
+	return git.DeleteFork(ctx, pr.Fork)
+}
+
+// checkForeignCommits fails when the branch of pr holds commits proliferate did
+// not make, going by the status and by the branch as it is now on the remote
+func (m *PRStatusManager) checkForeignCommits(ctx context.Context, git *mygit.Git, pr PRStatus) error {
+	if pr.UpdateRefused || len(pr.ForeignCommits) > 0 {
+		return fmt.Errorf("branch %s has commits not made by proliferate, use --force to delete it anyway", pr.Branch)
+	}
+	if pr.Branch == "" || pr.Repository == "" {
+		return nil
+	}
+
+	repoDir, err := git.Clone(pr.Repository, mygit.CloneOptions{Branch: pr.BaseBranch})
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(repoDir)
+
+	remote := "origin"
+	if pr.Fork != "" {
+		remote = forkRemote
+		if err := git.AddRemote(repoDir, remote, pr.Fork); err != nil {
+			return err
+		}
+	}
+	head, err := git.FetchRemoteBranch(repoDir, remote, pr.Branch)
+	if err != nil || head == "" {
+		return err
+	}
+	base, err := git.ResolveBaseBranch(ctx, pr.Repository, repoDir, pr.BaseBranch)
+	if err != nil {
+		return err
+	}
+
+	var ours []string
+	if pr.LastCommit != "" {
+		ours = append(ours, pr.LastCommit)
+	}
+	foreign, err := git.ForeignCommits(repoDir, base, remote, pr.Branch, ours)
+	if err != nil {
+		return err
+	}
+	if len(foreign) > 0 {
+		return fmt.Errorf("branch %s has %d commits not made by proliferate, use --force to delete it anyway", pr.Branch, len(foreign))
+	}
+	return nil
+}
+
+// forkInUse reports whether any status entry other than namespace/name pushes to fork
+func (m *PRStatusManager) forkInUse(fork, namespace, name string) (bool, error) {
+	status, err := m.loadAll()
+	if err != nil {
+		return false, err
+	}
+	for ns, prs := range status {
+		for n, pr := range prs {
+			if pr.Fork == fork && (ns != namespace || n != name) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// Remove deletes the status entry and patch of a PR, and the namespace once it is empty
+func (m *PRStatusManager) Remove(namespace, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	status, err := m.loadAll()
+	if err != nil {
+		return err
+	}
+
+	delete(status[namespace], name)
+	if len(status[namespace]) == 0 {
+		delete(status, namespace)
+	}
+
+	if err := m.saveAll(status); err != nil {
+		return err
+	}
+	return m.RemovePatch(namespace, name)
+}
@@ -32,6 +32,9 @@ type PRStatus = types.PRStatus
 type NamespacedStatus map[string]map[string]PRStatus
 
 func (m *PRStatusManager) SaveStatus(namespace string, status PRStatus) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	allStatus, err := m.loadAll()
 	if err != nil {
 		return err
@@ -46,6 +49,9 @@ func (m *PRStatusManager) SaveStatus(namespace string, status PRStatus) error {
 }
 
 func (m *PRStatusManager) GetNamespaces() ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	status, err := m.loadAll()
 	if err != nil {
 		return nil, err
@@ -59,6 +65,9 @@ func (m *PRStatusManager) GetNamespaces() ([]string, error) {
 }
 
 func (m *PRStatusManager) GetByNamespace(namespace string) (map[string]PRStatus, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	status, err := m.loadAll()
 	if err != nil {
 		return nil, err
@@ -67,6 +76,18 @@ func (m *PRStatusManager) GetByNamespace(namespace string) (map[string]PRStatus,
 	return status[namespace], nil
 }
 
+// Get returns the stored status of a PR, or an empty status when there is none
+func (m *PRStatusManager) Get(namespace, name string) (PRStatus, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	status, err := m.loadAll()
+	if err != nil {
+		return PRStatus{}, err
+	}
+	return status[namespace][name], nil
+}
+
 func (m *PRStatusManager) loadAll() (NamespacedStatus, error) {
 	statusFile := filepath.Join(m.statusDir, "status.yaml")
 	status := make(NamespacedStatus)
@@ -97,7 +118,23 @@ func (m *PRStatusManager) saveAll(status NamespacedStatus) error {
 		return fmt.Errorf("failed to marshal status: %v", err)
 	}
 
-	if err := os.WriteFile(filepath.Join(m.statusDir, "status.yaml"), data, 0644); err != nil {
+	// Replace the file in one step, so concurrent readers never see it half written
+	tmp, err := os.CreateTemp(m.statusDir, ".status-*.yaml")
+	if err != nil {
+		return fmt.Errorf("failed to write status file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write status file: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write status file: %v", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return fmt.Errorf("failed to write status file: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(m.statusDir, "status.yaml")); err != nil {
 		return fmt.Errorf("failed to write status file: %v", err)
 	}
 
@@ -148,13 +185,26 @@ func (m *PRStatusManager) DisplayNamespaceDetails(ctx context.Context, namespace
 		wg.Add(1)
 		go func(name string, pr PRStatus) {
 			defer wg.Done()
-			owner, repoName, err := git.ParseRepoString(pr.Repository)
-			if err != nil {
-				resultChan <- prResult{name: name, err: err}
+			// Repositories skipped by a failure policy or without changes may never have had a PR
+			if pr.PRNumber == 0 {
+				state := "pending"
+				if pr.Skipped {
+					state = "skipped"
+				} else if pr.NoChanges {
+					state = "no-changes"
+				}
+				resultChan <- prResult{name: name, pr: pr, state: state}
 				return
 			}
 
-			state, err := git.GetPRStatus(ctx, owner, repoName, pr.PRNumber)
+			state, err := git.GetPRStatus(ctx, pr.Repository, pr.PRNumber)
+
+			// Auto-merge is disarmed by the host when e.g. new commits are pushed
+			if err == nil && state == "open" && pr.AutoMerge == types.AutoMergeArmed {
+				if armed, armedErr := git.AutoMergeEnabled(ctx, pr.Repository, pr.PRNumber); armedErr == nil && !armed {
+					pr.AutoMerge = types.AutoMergeDisarmed
+				}
+			}
 			resultChan <- prResult{name: name, pr: pr, state: state, err: err}
 		}(name, pr)
 	}
@@ -0,0 +1,134 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ScriptStep is a single step executed inside the cloned repository.
+// It can be written either as a plain string, which is run through `sh -c`,
+// or as an object with an explicit command, args and environment.
+type ScriptStep struct {
+	Name          string            `yaml:"name,omitempty"`
+	Script        string            `yaml:"script,omitempty"`
+	Command       []string          `yaml:"command,omitempty"`
+	Args          []string          `yaml:"args,omitempty"`
+	Env           map[string]string `yaml:"env,omitempty"`
+	WorkingDir    string            `yaml:"workingDir,omitempty"`
+	FailurePolicy *FailurePolicy    `yaml:"failurePolicy,omitempty"`
+}
+
+// scriptStepFields avoids recursing into ScriptStep's own (un)marshalers
+type scriptStepFields ScriptStep
+
+func (s *ScriptStep) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*s = ScriptStep{Script: value.Value}
+		return nil
+	}
+
+	var fields scriptStepFields
+	if err := value.Decode(&fields); err != nil {
+		return err
+	}
+	*s = ScriptStep(fields)
+
+	if s.Script == "" && len(s.Command) == 0 {
+		return fmt.Errorf("script step %q must define either script or command", s.Name)
+	}
+	if s.Script != "" && len(s.Command) > 0 {
+		return fmt.Errorf("script step %q cannot define both script and command", s.Name)
+	}
+	return nil
+}
+
+func (s ScriptStep) MarshalYAML() (interface{}, error) {
+	// Keep the legacy string form when nothing else was set
+	if s.Name == "" && len(s.Command) == 0 && len(s.Args) == 0 && len(s.Env) == 0 && s.WorkingDir == "" && s.FailurePolicy == nil {
+		return s.Script, nil
+	}
+	return scriptStepFields(s), nil
+}
+
+// DisplayName returns the step name, falling back to the command it runs
+func (s ScriptStep) DisplayName() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	if s.Script != "" {
+		return s.Script
+	}
+	return strings.Join(append(append([]string{}, s.Command...), s.Args...), " ")
+}
+
+const (
+	FailureActionAbort    = "abort"
+	FailureActionContinue = "continue"
+	FailureActionSkipRepo = "skip-repo"
+)
+
+const (
+	ScriptOutcomeSucceeded = "succeeded"
+	ScriptOutcomeFailed    = "failed"
+	ScriptOutcomeIgnored   = "ignored"
+	ScriptOutcomeContinued = "continued"
+	ScriptOutcomeSkipped   = "skipped-repo"
+)
+
+// FailurePolicy decides what happens when a script step fails. It can be
+// written as a bare action (`failurePolicy: continue`) or as an object.
+type FailurePolicy struct {
+	Action          string `yaml:"action,omitempty"`
+	IgnoreExitCodes []int  `yaml:"ignoreExitCodes,omitempty"`
+}
+
+type failurePolicyFields FailurePolicy
+
+func (p *FailurePolicy) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*p = FailurePolicy{Action: value.Value}
+	} else {
+		var fields failurePolicyFields
+		if err := value.Decode(&fields); err != nil {
+			return err
+		}
+		*p = FailurePolicy(fields)
+	}
+
+	switch p.Action {
+	case "", FailureActionAbort, FailureActionContinue, FailureActionSkipRepo:
+		return nil
+	default:
+		return fmt.Errorf("unknown failure policy action %q, expected one of %s, %s or %s",
+			p.Action, FailureActionAbort, FailureActionContinue, FailureActionSkipRepo)
+	}
+}
+
+// EffectiveFailurePolicy merges a step policy over the spec policy, defaulting to abort
+func EffectiveFailurePolicy(spec, step *FailurePolicy) FailurePolicy {
+	policy := FailurePolicy{Action: FailureActionAbort}
+	for _, p := range []*FailurePolicy{spec, step} {
+		if p == nil {
+			continue
+		}
+		if p.Action != "" {
+			policy.Action = p.Action
+		}
+		if len(p.IgnoreExitCodes) > 0 {
+			policy.IgnoreExitCodes = p.IgnoreExitCodes
+		}
+	}
+	return policy
+}
+
+// IgnoresExitCode reports whether a failing exit code should be treated as success
+func (p FailurePolicy) IgnoresExitCode(code int) bool {
+	for _, c := range p.IgnoreExitCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
@@ -1,6 +1,7 @@
 package types
 
 import (
+	"fmt"
 	"time"
 )
 
@@ -12,25 +13,114 @@ type Printer interface {
 	PrintPRConfig(pr interface{})
 	PrintInfo(format string, args ...interface{})
 	PrintDiff(diff string)
+	PrintPatch(patch string)
 	PrintScriptOutput(script string, output []byte, err error)
 }
 
 type PRStatus struct {
-	Name         string    `yaml:"name"`
-	LastRendered string    `yaml:"lastRendered"`
-	LastApplied  time.Time `yaml:"lastApplied"`
-	PRNumber     int       `yaml:"prNumber"`
-	PRUrl        string    `yaml:"prUrl"`
-	Branch       string    `yaml:"branch"`
-	Repository   string    `yaml:"repository"`
-	LastDiff     string    `yaml:"lastDiff"`
-	LastCommit   string    `yaml:"lastCommit"`
-	LastError    string    `yaml:"lastError,omitempty"`
-	LastErrorAt  time.Time `yaml:"lastErrorAt,omitempty"`
+	Name               string         `yaml:"name"`
+	LastRendered       string         `yaml:"lastRendered"`
+	LastApplied        time.Time      `yaml:"lastApplied"`
+	PRNumber           int            `yaml:"prNumber"`
+	PRUrl              string         `yaml:"prUrl"`
+	Branch             string         `yaml:"branch"`
+	BaseBranch         string         `yaml:"baseBranch,omitempty"`
+	Repository         string         `yaml:"repository"`
+	Fork               string         `yaml:"fork,omitempty"`
+	LastDiff           string         `yaml:"lastDiff"`
+	PatchTruncated     bool           `yaml:"patchTruncated,omitempty"`
+	LastCommit         string         `yaml:"lastCommit"`
+	Verification       string         `yaml:"verification,omitempty"`
+	VerificationReason string         `yaml:"verificationReason,omitempty"`
+	LastError          string         `yaml:"lastError,omitempty"`
+	LastErrorAt        time.Time      `yaml:"lastErrorAt,omitempty"`
+	UpdateRefused      bool           `yaml:"updateRefused,omitempty"`
+	ForeignCommits     []string       `yaml:"foreignCommits,omitempty"`
+	NoChanges          bool           `yaml:"noChanges,omitempty"`
+	Draft              bool           `yaml:"draft,omitempty"`
+	AutoMerge          string         `yaml:"autoMerge,omitempty"`
+	MergeCommit        string         `yaml:"mergeCommit,omitempty"`
+	MergedAt           time.Time      `yaml:"mergedAt,omitempty"`
+	Skipped            bool           `yaml:"skipped,omitempty"`
+	SkipReason         string         `yaml:"skipReason,omitempty"`
+	Warnings           []string       `yaml:"warnings,omitempty"`
+	Scripts            []ScriptStatus `yaml:"scripts,omitempty"`
+}
+
+// ScriptStatus records the outcome of a single script step from the last run
+type ScriptStatus struct {
+	Name     string        `yaml:"name"`
+	ExitCode int           `yaml:"exitCode"`
+	Policy   string        `yaml:"policy,omitempty"`
+	Outcome  string        `yaml:"outcome"`
+	Duration time.Duration `yaml:"duration"`
+	Error    string        `yaml:"error,omitempty"`
 }
 
 type NamespacedStatus map[string]map[string]PRStatus
 
+// Auto-merge states recorded in PRStatus.AutoMerge
+const (
+	AutoMergeArmed    = "armed"
+	AutoMergeDisarmed = "disarmed"
+	AutoMergeFailed   = "failed"
+)
+
+// Signature states of the last commit recorded in PRStatus.Verification
+const (
+	CommitVerified   = "verified"
+	CommitUnverified = "unverified"
+)
+
+// Update strategies decide what happens when the PR branch already exists
+// and holds commits that proliferate did not make
+const (
+	// UpdateStrategyRecreate regenerates the branch from base and force-pushes over it
+	UpdateStrategyRecreate = "recreate"
+	// UpdateStrategyRebase replays the foreign commits on base with the regenerated commit on top
+	UpdateStrategyRebase = "rebase"
+	// UpdateStrategyRefuseIfModified leaves the branch untouched and reports it
+	UpdateStrategyRefuseIfModified = "refuse-if-modified"
+)
+
+// Plan is the reviewed outcome of pro pr plan, which pro pr apply --plan
+// executes without re-rendering the template
+type Plan struct {
+	CreatedAt time.Time   `yaml:"createdAt"`
+	Entries   []PlanEntry `yaml:"entries"`
+}
+
+// PlanEntry is what applying one pull request would do. BaseCommit,
+// RemoteHead and PatchSHA256 pin the state apply must still find.
+type PlanEntry struct {
+	Action      string `yaml:"action"`
+	Namespace   string `yaml:"namespace"`
+	Name        string `yaml:"name"`
+	Repository  string `yaml:"repository"`
+	Branch      string `yaml:"branch"`
+	BaseBranch  string `yaml:"baseBranch,omitempty"`
+	BaseCommit  string `yaml:"baseCommit,omitempty"`
+	RemoteHead  string `yaml:"remoteHead,omitempty"`
+	PRNumber    int    `yaml:"prNumber,omitempty"`
+	Diff        string `yaml:"diff,omitempty"`
+	PatchSHA256 string `yaml:"patchSha256,omitempty"`
+	// BranchDiff is the diff stat against the existing remote branch
+	BranchDiff string `yaml:"branchDiff,omitempty"`
+	// MetadataChanges lists the PR fields that applying would change
+	MetadataChanges []string    `yaml:"metadataChanges,omitempty"`
+	Reason          string      `yaml:"reason,omitempty"`
+	PullRequest     PullRequest `yaml:"pullRequest"`
+}
+
+// Plan actions recorded in PlanEntry.Action
+const (
+	PlanActionCreate    = "create"
+	PlanActionUpdate    = "update"
+	PlanActionNoChanges = "no-changes"
+	PlanActionSkip      = "skip"
+	PlanActionFail      = "fail"
+)
+
 // PullRequest represents the PR configuration
 type PullRequest struct {
 	APIVersion string `yaml:"apiVersion"`
@@ -44,18 +134,131 @@ type PullRequest struct {
 		Org              string            `yaml:"organization"`
 		RepositoryFilter string            `yaml:"repositoryFilter"`
 		Branch           string            `yaml:"branch"`
+		BaseBranch       string            `yaml:"baseBranch,omitempty"`
+		UpdateStrategy   string            `yaml:"updateStrategy,omitempty"`
+		CloseIfNoChanges bool              `yaml:"closeIfNoChanges,omitempty"`
+		AutoMerge        *AutoMerge        `yaml:"autoMerge,omitempty"`
 		CommitMessage    string            `yaml:"commitMessage"`
+		CommitAuthor     *CommitIdentity   `yaml:"commitAuthor,omitempty"`
+		CoAuthors        []CommitIdentity  `yaml:"coAuthors,omitempty"`
 		PRTitle          string            `yaml:"prTitle"`
 		PRBody           string            `yaml:"prBody"`
 		PRLabels         []string          `yaml:"prLabels"`
 		PRAssignees      []string          `yaml:"prAssignees"`
+		PRReviewers      []string          `yaml:"prReviewers,omitempty"`
+		PRTeamReviewers  []string          `yaml:"prTeamReviewers,omitempty"`
+		Draft            bool              `yaml:"draft,omitempty"`
+		Fork             *Fork             `yaml:"fork,omitempty"`
+		Checkout         *Checkout         `yaml:"checkout,omitempty"`
 		ScriptsContext   map[string]string `yaml:"scriptsContext"`
-		Scripts          []string          `yaml:"scripts"`
+		FailurePolicy    *FailurePolicy    `yaml:"failurePolicy,omitempty"`
+		Scripts          []ScriptStep      `yaml:"scripts"`
 	} `yaml:"spec"`
 }
 
+// AutoMerge asks the host to merge the PR by itself once its checks and reviews pass
+type AutoMerge struct {
+	Enabled     bool   `yaml:"enabled"`
+	MergeMethod string `yaml:"mergeMethod,omitempty"`
+	// CommitHeadline is a Go template rendered with the created PR as .Number, .Title, .Repo, .Branch and .URL
+	CommitHeadline string `yaml:"commitHeadline,omitempty"`
+}
+
+// CommitIdentity is a git author or co-author, e.g. a GitHub noreply address
+type CommitIdentity struct {
+	Name  string `yaml:"name"`
+	Email string `yaml:"email"`
+}
+
+// String formats the identity the way git writes authors and trailers
+func (i CommitIdentity) String() string {
+	return fmt.Sprintf("%s <%s>", i.Name, i.Email)
+}
+
+// Checkout bounds the clone of large repositories
+type Checkout struct {
+	// Depth limits the cloned history, deepened automatically when an update strategy needs more
+	Depth int `yaml:"depth,omitempty"`
+	// SparsePaths limits the working tree to these directories
+	SparsePaths []string `yaml:"sparsePaths,omitempty"`
+	// Filter is a partial clone filter, e.g. blob:none
+	Filter string `yaml:"filter,omitempty"`
+}
+
+// Fork pushes the PR branch to a fork, for repositories proliferate can only read
+type Fork struct {
+	Enabled bool `yaml:"enabled"`
+	// Owner is the organization or user owning the fork, the authenticated account by default
+	Owner string `yaml:"owner,omitempty"`
+}
+
 type Config interface {
 	GetGithubToken() string
 	GetAuthorEmail() string
 	GetAuthorName() string
+	GetRepos() []string
+	GetProviders() []ProviderConfig
+	GetGithubApp() *GitHubAppConfig
+	GetSigning() *SigningConfig
+	GetCache() *CacheConfig
+	GetGitBackend() string
+}
+
+// Git backends running the git operations on clones
+const (
+	// GitBackendExec runs the git binary
+	GitBackendExec = "exec"
+	// GitBackendGoGit runs git in process with go-git, without a git binary
+	GitBackendGoGit = "go-git"
+)
+
+// CacheConfig controls the local mirrors repositories are cloned from
+type CacheConfig struct {
+	// Dir holds the mirrors, defaults to proliferate under the user cache directory
+	Dir string `mapstructure:"dir" yaml:"dir"`
+	// MaxSizeMB evicts the least recently used mirrors after apply, 0 means no limit
+	MaxSizeMB int64 `mapstructure:"max-size-mb" yaml:"max-size-mb"`
+	// Disabled clones every repository from scratch
+	Disabled bool `mapstructure:"disabled" yaml:"disabled"`
+}
+
+// Commit signing formats
+const (
+	SigningFormatGPG = "gpg"
+	SigningFormatSSH = "ssh"
+)
+
+// SigningConfig signs the commits made by proliferate
+type SigningConfig struct {
+	// Format is gpg or ssh
+	Format string `mapstructure:"format" yaml:"format"`
+	// Key is a GPG key ID, or the path of an SSH private or public key
+	Key string `mapstructure:"key" yaml:"key"`
+	// Program overrides the gpg or ssh-keygen binary used to sign
+	Program string `mapstructure:"program" yaml:"program"`
+}
+
+// ProviderConfig describes an additional code hosting service, selected by
+// the host part of `spec.repo`
+type ProviderConfig struct {
+	Host    string `mapstructure:"host" yaml:"host"`
+	Type    string `mapstructure:"type" yaml:"type"`
+	BaseURL string `mapstructure:"base-url" yaml:"base-url"`
+	Token   string `mapstructure:"token" yaml:"token"`
+	// UploadURL is only used by GitHub Enterprise Server
+	UploadURL string `mapstructure:"upload-url" yaml:"upload-url"`
+	// CloneHost overrides the host used for git clone and push
+	CloneHost string `mapstructure:"clone-host" yaml:"clone-host"`
+	// Username is only needed by providers that authenticate with basic auth, like Bitbucket
+	Username string `mapstructure:"username" yaml:"username"`
+	// App authenticates GitHub providers as a GitHub App instead of a token
+	App *GitHubAppConfig `mapstructure:"github-app" yaml:"github-app"`
+}
+
+// GitHubAppConfig authenticates as a GitHub App installation. Without an
+// installation ID, the installation is discovered for each organization.
+type GitHubAppConfig struct {
+	AppID          int64  `mapstructure:"app-id" yaml:"app-id"`
+	PrivateKeyPath string `mapstructure:"private-key-path" yaml:"private-key-path"`
+	InstallationID int64  `mapstructure:"installation-id" yaml:"installation-id"`
 }